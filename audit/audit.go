@@ -0,0 +1,308 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+// Package audit records the structured audit events of PAM-SSHCA, such as the grants and the denials,
+// to the sinks configured by the AuditSink directive.
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/syslog"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// The types of the audit events.
+const (
+	// TypeGrant is the event that the request is granted.
+	TypeGrant = "grant"
+	// TypeDeny is the event that the request is denied.
+	TypeDeny = "deny"
+	// TypeFirefighter is the event that a firefighter (break-glass) certificate is used.
+	TypeFirefighter = "firefighter"
+	// TypeNotifyFailed is the event that a notifier of FirefighterNotify fails.
+	TypeNotifyFailed = "notify-failed"
+	// TypeReceipt is the event that the receipt of a grant is saved.
+	TypeReceipt = "receipt"
+	// TypePrincipalMap is the event that a certificate is authorized by a rule of PrincipalMap.
+	TypePrincipalMap = "principal-map"
+)
+
+// The reason codes of the audit events. The denials by FallbackOn are also reasoned by the conditions,
+// such as conf.FallbackNoAgent.
+const (
+	// ReasonStaticKey is the grant by a static key.
+	ReasonStaticKey = "static-key"
+	// ReasonCertificate is the grant by a certificate.
+	ReasonCertificate = "certificate"
+	// ReasonCommandPolicy is the denial by a CommandPolicy.
+	ReasonCommandPolicy = "command-policy"
+	// ReasonApprovalFailed is the denial after the challenge succeeds, such as a replayed nonce certificate.
+	ReasonApprovalFailed = "approval-failed"
+	// ReasonReceiptFailed is the denial on the failure to save the receipt of the grant.
+	ReasonReceiptFailed = "receipt-failed"
+	// ReasonFallbackFailed is the denial by the failure of the fallback authentication methods.
+	ReasonFallbackFailed = "fallback-failed"
+	// ReasonMaxTries is the denial after the user runs out of the retries of the fallback authentication methods.
+	ReasonMaxTries = "max-tries"
+)
+
+// eventTypes are the labels in the text messages and the syslog severities of the event types.
+var eventTypes = map[string]struct {
+	label    string
+	severity syslog.Priority
+}{
+	TypeGrant:        {"Grant", syslog.LOG_INFO},
+	TypeDeny:         {"Deny", syslog.LOG_WARNING},
+	TypeFirefighter:  {"Firefighter", syslog.LOG_CRIT},
+	TypeNotifyFailed: {"Firefighter notification failed", syslog.LOG_WARNING},
+	TypeReceipt:      {"Receipt", syslog.LOG_INFO},
+	TypePrincipalMap: {"PrincipalMap", syslog.LOG_INFO},
+}
+
+// Event is a structured audit event.
+type Event struct {
+	// Time is the time of the event.
+	Time time.Time `json:"time"`
+	// Type is the event type, such as TypeGrant.
+	Type string `json:"event"`
+	// Reason is the reason code, such as ReasonCertificate.
+	Reason string `json:"reason,omitempty"`
+	// User is the user to authenticate.
+	User string `json:"user,omitempty"`
+	// Target is the user that the command runs as, such as the user of `sudo -u`.
+	Target string `json:"target,omitempty"`
+	// Service is the PAM service name, such as "sudo".
+	Service string `json:"service,omitempty"`
+	// TTY is the terminal of the PAM application.
+	TTY string `json:"tty,omitempty"`
+	// Command is the command line of the PAM application.
+	Command string `json:"command,omitempty"`
+	// RemoteAddr is the address of the remote host that the user connects from.
+	RemoteAddr string `json:"remoteAddr,omitempty"`
+	// Host is the hostname of the local host.
+	Host string `json:"host,omitempty"`
+	// StaticKey is the SHA256 fingerprint of the static key.
+	StaticKey string `json:"staticKey,omitempty"`
+	// CertFingerprint is the SHA256 fingerprint of the key of the certificate.
+	CertFingerprint string `json:"certFingerprint,omitempty"`
+	// CertSerial is the serial of the certificate.
+	CertSerial uint64 `json:"certSerial,omitempty"`
+	// KeyID is the Key ID of the certificate.
+	KeyID string `json:"keyID,omitempty"`
+	// KeyIDFields are the fields of the Key ID, if the Key ID is a JSON object.
+	KeyIDFields map[string]string `json:"keyIDFields,omitempty"`
+	// CAFingerprint is the SHA256 fingerprint of the CA that signs the certificate.
+	CAFingerprint string `json:"caFingerprint,omitempty"`
+	// CALabel is the label of the CA in TrustedUserCAKeys, which is the comment of the CA key.
+	CALabel string `json:"caLabel,omitempty"`
+	// Error is the error that causes the event, such as the error of a denial.
+	Error string `json:"error,omitempty"`
+	// Details are the additional fields of the event type, such as the justification of a firefighter.
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// Audit records the audit events.
+type Audit interface {
+	// Record records the event.
+	Record(e *Event) error
+}
+
+// SetKey sets the fields of the static key or the certificate that the event is about.
+func (e *Event) SetKey(pub ssh.PublicKey) {
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		e.StaticKey = ssh.FingerprintSHA256(pub)
+		return
+	}
+	if cert.Key != nil {
+		e.CertFingerprint = ssh.FingerprintSHA256(cert.Key)
+	}
+	e.CertSerial = cert.Serial
+	e.KeyID = cert.KeyId
+	e.KeyIDFields = keyIDFields(cert.KeyId)
+	if cert.SignatureKey != nil {
+		e.CAFingerprint = ssh.FingerprintSHA256(cert.SignatureKey)
+	}
+}
+
+// keyIDFields returns the fields of the Key ID in JSON, or nil if the Key ID is not a JSON object.
+func keyIDFields(keyID string) map[string]string {
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(keyID), &obj); err != nil || len(obj) == 0 {
+		return nil
+	}
+	fields := make(map[string]string, len(obj))
+	for name, value := range obj {
+		switch v := value.(type) {
+		case string:
+			fields[name] = v
+		case []interface{}:
+			values := make([]string, len(v))
+			for i := range v {
+				values[i] = fmt.Sprint(v[i])
+			}
+			fields[name] = strings.Join(values, ",")
+		default:
+			fields[name] = fmt.Sprint(v)
+		}
+	}
+	return fields
+}
+
+// Severity returns the syslog severity of the event.
+func (e *Event) Severity() syslog.Priority {
+	if t, ok := eventTypes[e.Type]; ok {
+		return t.severity
+	}
+	return syslog.LOG_NOTICE
+}
+
+// String returns the text message of the event, such as
+// `Grant: USER=alice, SERVICE=sudo, KEYID=(...), CMD=(sudo ls), REASON=certificate`.
+func (e *Event) String() string {
+	label := e.Type
+	if t, ok := eventTypes[e.Type]; ok {
+		label = t.label
+	}
+	var params []string
+	add := func(name, format, value string) {
+		if value != "" {
+			params = append(params, name+"="+fmt.Sprintf(format, value))
+		}
+	}
+	add("USER", "%s", e.User)
+	add("TARGET", "%s", e.Target)
+	add("SERVICE", "%s", e.Service)
+	add("TTY", "%s", e.TTY)
+	add("RHOST", "%s", e.RemoteAddr)
+	add("STATIC_KEY", "%s", e.StaticKey)
+	add("KEYID", "(%s)", e.KeyID)
+	add("CA", "%s", e.CALabel)
+	add("CMD", "(%s)", e.Command)
+	add("REASON", "%s", e.Reason)
+	add("ERR", "(%s)", e.Error)
+	for _, name := range sortedKeys(e.Details) {
+		add(strings.ToUpper(name), "(%s)", e.Details[name])
+	}
+	return label + ": " + strings.Join(params, ", ")
+}
+
+// field is a name and a value of the event.
+type field struct {
+	name, value string
+}
+
+// fields returns the non-empty fields of the event in a fixed order, named as in JSON.
+// The fields of the Key ID are prefixed by "keyID.", and the details are named as they are.
+func (e *Event) fields() []field {
+	var fields []field
+	add := func(name, value string) {
+		if value != "" {
+			fields = append(fields, field{name, value})
+		}
+	}
+	add("event", e.Type)
+	add("reason", e.Reason)
+	add("user", e.User)
+	add("target", e.Target)
+	add("service", e.Service)
+	add("tty", e.TTY)
+	add("command", e.Command)
+	add("remoteAddr", e.RemoteAddr)
+	add("host", e.Host)
+	add("staticKey", e.StaticKey)
+	add("certFingerprint", e.CertFingerprint)
+	if e.CertFingerprint != "" {
+		add("certSerial", fmt.Sprint(e.CertSerial))
+	}
+	add("keyID", e.KeyID)
+	for _, name := range sortedKeys(e.KeyIDFields) {
+		add("keyID."+name, e.KeyIDFields[name])
+	}
+	add("caFingerprint", e.CAFingerprint)
+	add("caLabel", e.CALabel)
+	add("error", e.Error)
+	for _, name := range sortedKeys(e.Details) {
+		add(name, e.Details[name])
+	}
+	return fields
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// The types of the sinks in the AuditSink directive.
+const (
+	// SinkSyslog writes the text messages of the events to the local syslog, as PAM-SSHCA did before the audit sinks.
+	SinkSyslog = "syslog"
+	// SinkJSON appends the events as JSON lines to the file.
+	SinkJSON = "json"
+	// SinkJournald sends the events with their fields to the native socket of systemd-journald.
+	SinkJournald = "journald"
+	// SinkRFC5424 sends the events in RFC 5424 syslog format, with the fields as structured data.
+	SinkRFC5424 = "rfc5424"
+)
+
+// NewSink returns the sink of the type with the target, which is
+//   - empty for SinkSyslog,
+//   - the absolute path of the file for SinkJSON,
+//   - the absolute path of the socket for SinkJournald, or empty for the default socket,
+//   - the URL of the syslog server for SinkRFC5424, such as unix:///dev/log, udp://host:514 or tcp://host:6514.
+//
+// The sinks connect to their targets on recording the events.
+func NewSink(typ, target string) (Audit, error) {
+	switch typ {
+	case SinkSyslog:
+		if target != "" {
+			return nil, fmt.Errorf("unexpected target %q of %s sink", target, typ)
+		}
+		return &syslogSink{}, nil
+	case SinkJSON:
+		if !filepath.IsAbs(target) {
+			return nil, fmt.Errorf("%s sink requires the absolute path of the file, got %q", typ, target)
+		}
+		return &jsonSink{path: target}, nil
+	case SinkJournald:
+		if target == "" {
+			target = journaldSocket
+		}
+		if !filepath.IsAbs(target) {
+			return nil, fmt.Errorf("%s sink requires the absolute path of the socket, got %q", typ, target)
+		}
+		return &journaldSink{socket: target}, nil
+	case SinkRFC5424:
+		return newRFC5424Sink(target)
+	}
+	return nil, fmt.Errorf("unknown audit sink type %q", typ)
+}
+
+// Multi returns the Audit that records the events to all the sinks.
+// A failed sink doesn't stop the others, and the errors of the sinks are joined.
+func Multi(sinks ...Audit) Audit {
+	return multi(sinks)
+}
+
+type multi []Audit
+
+func (m multi) Record(e *Event) error {
+	var errs []error
+	for _, sink := range m {
+		if err := sink.Record(e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,240 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package audit
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"reflect"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func newTestKey(t *testing.T) ssh.Signer {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func TestEvent_SetKey(t *testing.T) {
+	t.Parallel()
+	ca := newTestKey(t)
+	user := newTestKey(t)
+	cert := &ssh.Certificate{
+		Key:      user.PublicKey(),
+		Serial:   42,
+		KeyId:    `{"usr":"alice","touchPolicy":2,"prins":["alice","ops"]}`,
+		CertType: ssh.UserCert,
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		pub  ssh.PublicKey
+		want Event
+	}{
+		{
+			name: "static key",
+			pub:  user.PublicKey(),
+			want: Event{StaticKey: ssh.FingerprintSHA256(user.PublicKey())},
+		},
+		{
+			name: "certificate",
+			pub:  cert,
+			want: Event{
+				CertFingerprint: ssh.FingerprintSHA256(user.PublicKey()),
+				CertSerial:      42,
+				KeyID:           cert.KeyId,
+				KeyIDFields:     map[string]string{"usr": "alice", "touchPolicy": "2", "prins": "alice,ops"},
+				CAFingerprint:   ssh.FingerprintSHA256(ca.PublicKey()),
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var e Event
+			e.SetKey(tt.pub)
+			if !reflect.DeepEqual(e, tt.want) {
+				t.Errorf("SetKey() = %+v, want %+v", e, tt.want)
+			}
+		})
+	}
+}
+
+func Test_keyIDFields(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		keyID string
+		want  map[string]string
+	}{
+		{
+			name:  "json",
+			keyID: `{"usr":"alice","ver":1}`,
+			want:  map[string]string{"usr": "alice", "ver": "1"},
+		},
+		{
+			name:  "plain",
+			keyID: "alice-key",
+		},
+		{
+			name:  "empty object",
+			keyID: "{}",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := keyIDFields(tt.keyID); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("keyIDFields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvent_String(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		event Event
+		want  string
+	}{
+		{
+			name: "grant",
+			event: Event{
+				Type:    TypeGrant,
+				Reason:  ReasonCertificate,
+				User:    "alice",
+				Target:  "root",
+				Service: "sudo",
+				KeyID:   "alice-key",
+				CALabel: "prod-ca",
+				Command: "sudo ls",
+			},
+			want: "Grant: USER=alice, TARGET=root, SERVICE=sudo, KEYID=(alice-key), CA=prod-ca, CMD=(sudo ls), REASON=certificate",
+		},
+		{
+			name: "deny with details",
+			event: Event{
+				Type:    TypeDeny,
+				Reason:  ReasonFallbackFailed,
+				User:    "alice",
+				Error:   "timeout",
+				Details: map[string]string{"method": "cryptoauth"},
+			},
+			want: "Deny: USER=alice, REASON=fallback-failed, ERR=(timeout), METHOD=(cryptoauth)",
+		},
+		{
+			name:  "unknown type",
+			event: Event{Type: "custom", User: "alice"},
+			want:  "custom: USER=alice",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.event.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvent_fields(t *testing.T) {
+	t.Parallel()
+	e := Event{
+		Type:            TypeGrant,
+		User:            "alice",
+		CertFingerprint: "SHA256:abc",
+		KeyID:           "{...}",
+		KeyIDFields:     map[string]string{"usr": "alice", "prins": "alice"},
+		Details:         map[string]string{"method": "cryptoauth"},
+	}
+	want := []field{
+		{"event", "grant"},
+		{"user", "alice"},
+		{"certFingerprint", "SHA256:abc"},
+		{"certSerial", "0"},
+		{"keyID", "{...}"},
+		{"keyID.prins", "alice"},
+		{"keyID.usr", "alice"},
+		{"method", "cryptoauth"},
+	}
+	if got := e.fields(); !reflect.DeepEqual(got, want) {
+		t.Errorf("fields() = %v, want %v", got, want)
+	}
+}
+
+func TestNewSink(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		typ     string
+		target  string
+		wantErr bool
+	}{
+		{name: "syslog", typ: SinkSyslog},
+		{name: "syslog with target", typ: SinkSyslog, target: "/dev/log", wantErr: true},
+		{name: "json", typ: SinkJSON, target: "/var/log/pam_sshca.json"},
+		{name: "json relative path", typ: SinkJSON, target: "pam_sshca.json", wantErr: true},
+		{name: "journald default socket", typ: SinkJournald},
+		{name: "journald relative socket", typ: SinkJournald, target: "socket", wantErr: true},
+		{name: "rfc5424 unix", typ: SinkRFC5424, target: "unix:///dev/log"},
+		{name: "rfc5424 udp", typ: SinkRFC5424, target: "udp://syslog.example.com:514"},
+		{name: "rfc5424 without port", typ: SinkRFC5424, target: "tcp://syslog.example.com", wantErr: true},
+		{name: "rfc5424 without scheme", typ: SinkRFC5424, target: "syslog.example.com", wantErr: true},
+		{name: "unknown", typ: "kafka", wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := NewSink(tt.typ, tt.target); (err != nil) != tt.wantErr {
+				t.Errorf("NewSink() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// sinkFunc is the Audit of a function.
+type sinkFunc func(e *Event) error
+
+func (f sinkFunc) Record(e *Event) error { return f(e) }
+
+func TestMulti(t *testing.T) {
+	t.Parallel()
+	errFirst := errors.New("first sink failed")
+	var recorded []string
+	sinks := Multi(
+		sinkFunc(func(e *Event) error { return errFirst }),
+		sinkFunc(func(e *Event) error {
+			recorded = append(recorded, e.User)
+			return nil
+		}),
+	)
+	err := sinks.Record(&Event{Type: TypeGrant, User: "alice"})
+	if !errors.Is(err, errFirst) {
+		t.Errorf("Record() error = %v, want %v", err, errFirst)
+	}
+	if !reflect.DeepEqual(recorded, []string{"alice"}) {
+		t.Errorf("Record() recorded %v by the second sink, want [alice]", recorded)
+	}
+	if err := Multi().Record(&Event{}); err != nil {
+		t.Errorf("Record() of no sinks error = %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package audit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log/syslog"
+	"net"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// journaldSocket is the native socket of systemd-journald.
+const journaldSocket = "/run/systemd/journal/socket"
+
+// journaldFieldPrefix is the prefix of the journal fields of the events, such as PAM_SSHCA_KEY_ID.
+const journaldFieldPrefix = "PAM_SSHCA_"
+
+// journaldSink sends the events to systemd-journald by its native protocol.
+// Please refer to https://systemd.io/JOURNAL_NATIVE_PROTOCOL/ for the protocol.
+type journaldSink struct {
+	socket string
+}
+
+func (s *journaldSink) Record(e *Event) error {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: s.socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write(journaldMessage(e))
+	return err
+}
+
+// journaldMessage returns the datagram of the event in the native protocol of systemd-journald.
+func journaldMessage(e *Event) []byte {
+	var buf bytes.Buffer
+	add := func(name, value string) {
+		if !strings.Contains(value, "\n") {
+			fmt.Fprintf(&buf, "%s=%s\n", name, value)
+			return
+		}
+		// The values with newlines are in the binary format: the name, the little-endian 64-bit size and the value.
+		buf.WriteString(name + "\n")
+		binary.Write(&buf, binary.LittleEndian, uint64(len(value))) //nolint:errcheck
+		buf.WriteString(value + "\n")
+	}
+	add("MESSAGE", e.String())
+	add("PRIORITY", fmt.Sprint(int(e.Severity())))
+	add("SYSLOG_FACILITY", fmt.Sprint(int(syslog.LOG_AUTHPRIV>>3)))
+	add("SYSLOG_IDENTIFIER", syslogTag)
+	add("SYSLOG_PID", fmt.Sprint(os.Getpid()))
+	for _, f := range e.fields() {
+		add(journaldFieldPrefix+journaldFieldName(f.name), f.value)
+	}
+	return buf.Bytes()
+}
+
+// journaldFieldName returns the journal field name of the event field, such as KEY_ID_TOUCH_POLICY for keyID.touchPolicy.
+// The journal field names consist of uppercase letters, digits and underscores.
+func journaldFieldName(name string) string {
+	var b strings.Builder
+	prev := rune(0)
+	for _, r := range name {
+		switch {
+		case unicode.IsUpper(r) && (unicode.IsLower(prev) || unicode.IsDigit(prev)):
+			b.WriteRune('_')
+			b.WriteRune(r)
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			b.WriteRune(unicode.ToUpper(r))
+		default:
+			b.WriteRune('_')
+		}
+		prev = r
+	}
+	return b.String()
+}
@@ -0,0 +1,123 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package audit
+
+import (
+	"fmt"
+	"log/syslog"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// rfc5424SDID is the ID of the structured data of the events.
+	// 32473 is the example private enterprise number of RFC 5612.
+	rfc5424SDID = "pam-sshca@32473"
+	// rfc5424Timeout is the maximum time to send each event.
+	rfc5424Timeout = 5 * time.Second
+	// rfc5424TimeFormat is RFC 3339 with at most 6 digits of the fraction of second, as RFC 5424 requires.
+	rfc5424TimeFormat = "2006-01-02T15:04:05.999999Z07:00"
+)
+
+// rfc5424Sink sends the events in RFC 5424 syslog format.
+// The events are sent in datagrams over unix and udp, and with the octet counting framing of RFC 6587 over tcp.
+type rfc5424Sink struct {
+	network, address string
+}
+
+func newRFC5424Sink(target string) (*rfc5424Sink, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL of %s sink: %v", SinkRFC5424, err)
+	}
+	switch u.Scheme {
+	case "unix":
+		if u.Path == "" {
+			return nil, fmt.Errorf("%s sink requires the path of the socket, such as unix:///dev/log", SinkRFC5424)
+		}
+		return &rfc5424Sink{network: "unixgram", address: u.Path}, nil
+	case "udp", "tcp":
+		if u.Port() == "" {
+			return nil, fmt.Errorf("%s sink requires the port of the syslog server, such as %s://host:514", SinkRFC5424, u.Scheme)
+		}
+		return &rfc5424Sink{network: u.Scheme, address: u.Host}, nil
+	}
+	return nil, fmt.Errorf("%s sink requires a unix, udp or tcp URL, got %q", SinkRFC5424, target)
+}
+
+func (s *rfc5424Sink) Record(e *Event) error {
+	hostname, _ := os.Hostname()
+	message := rfc5424Message(e, hostname, os.Getpid())
+
+	conn, err := net.DialTimeout(s.network, s.address, rfc5424Timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetWriteDeadline(time.Now().Add(rfc5424Timeout)); err != nil {
+		return err
+	}
+	if s.network == "tcp" {
+		message = append([]byte(fmt.Sprintf("%d ", len(message))), message...)
+	}
+	_, err = conn.Write(message)
+	return err
+}
+
+// rfc5424Message returns the syslog message of the event in RFC 5424 format, such as
+// `<84>1 2026-01-02T03:04:05.123456Z host PAM_SSHCA 1234 grant [pam-sshca@32473 event="grant" ...] Grant: ...`.
+func rfc5424Message(e *Event, hostname string, pid int) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %d %s [%s",
+		int(syslog.LOG_AUTHPRIV|e.Severity()),
+		e.Time.UTC().Format(rfc5424TimeFormat),
+		rfc5424Header(hostname, 255),
+		syslogTag,
+		pid,
+		rfc5424Header(e.Type, 32),
+		rfc5424SDID,
+	)
+	for _, f := range e.fields() {
+		fmt.Fprintf(&b, " %s=\"%s\"", rfc5424ParamName(f.name), rfc5424ParamValue(f.value))
+	}
+	b.WriteString("] ")
+	b.WriteString(e.String())
+	return []byte(b.String())
+}
+
+// rfc5424Header returns the header field of printable US-ASCII in at most max characters, or "-" if it is empty.
+func rfc5424Header(value string, max int) string {
+	value = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, value)
+	if len(value) > max {
+		value = value[:max]
+	}
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// rfc5424ParamName returns the PARAM-NAME of the structured data, which excludes '=', ' ', ']' and '"'.
+func rfc5424ParamName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, name)
+	return rfc5424Header(name, 32)
+}
+
+// rfc5424ParamValue returns the PARAM-VALUE of the structured data, where '"', '\' and ']' are escaped.
+func rfc5424ParamValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
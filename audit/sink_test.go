@@ -0,0 +1,204 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestEvent() *Event {
+	return &Event{
+		Time:        time.Date(2026, 1, 2, 3, 4, 5, 123456789, time.UTC),
+		Type:        TypeGrant,
+		Reason:      ReasonCertificate,
+		User:        "alice",
+		Command:     "sudo ls",
+		KeyID:       `{"usr":"alice"}`,
+		KeyIDFields: map[string]string{"usr": "alice"},
+		Error:       "line 1\nline 2",
+	}
+}
+
+func TestJSONSink(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "log", "audit.json")
+	sink, err := NewSink(SinkJSON, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := newTestEvent()
+	for i := 0; i < 2; i++ {
+		if err := sink.Record(want); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	lines := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines++
+		var got Event
+		if err := json.Unmarshal(scanner.Bytes(), &got); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", scanner.Text(), err)
+		}
+		if !reflect.DeepEqual(&got, want) {
+			t.Errorf("JSON line = %+v, want %+v", got, want)
+		}
+	}
+	if lines != 2 {
+		t.Errorf("got %d JSON lines, want 2", lines)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("JSON file mode = %v, want 0600", perm)
+	}
+}
+
+func TestJournaldSink(t *testing.T) {
+	t.Parallel()
+	socket := filepath.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	sink, err := NewSink(SinkJournald, socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Record(newTestEvent()); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	buf := make([]byte, 65536)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(buf[:n])
+	for _, want := range []string{
+		"PRIORITY=6\n",
+		"SYSLOG_IDENTIFIER=PAM_SSHCA\n",
+		"PAM_SSHCA_EVENT=grant\n",
+		"PAM_SSHCA_KEY_ID_USR=alice\n",
+		// The value with newlines is prefixed by its little-endian 64-bit size.
+		"PAM_SSHCA_ERROR\n\x0d\x00\x00\x00\x00\x00\x00\x00line 1\nline 2\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("journald message %q doesn't contain %q", got, want)
+		}
+	}
+	if strings.Contains(got, "PAM_SSHCA_REMOTE_ADDR") {
+		t.Errorf("journald message %q contains the empty field PAM_SSHCA_REMOTE_ADDR", got)
+	}
+}
+
+func Test_journaldFieldName(t *testing.T) {
+	t.Parallel()
+	tests := map[string]string{
+		"event":               "EVENT",
+		"remoteAddr":          "REMOTE_ADDR",
+		"keyID":               "KEY_ID",
+		"keyID.touchPolicy":   "KEY_ID_TOUCH_POLICY",
+		"caFingerprint":       "CA_FINGERPRINT",
+		"x509-sha256":         "X509_SHA256",
+		"justificationTicket": "JUSTIFICATION_TICKET",
+	}
+	for name, want := range tests {
+		if got := journaldFieldName(name); got != want {
+			t.Errorf("journaldFieldName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func Test_rfc5424Message(t *testing.T) {
+	t.Parallel()
+	e := newTestEvent()
+	e.Command = `sudo echo "a]b"`
+	e.Error = ""
+	got := string(rfc5424Message(e, "host name", 1234))
+	want := `<86>1 2026-01-02T03:04:05.123456Z host_name PAM_SSHCA 1234 grant ` +
+		`[pam-sshca@32473 event="grant" reason="certificate" user="alice" command="sudo echo \"a\]b\"" ` +
+		`keyID="{\"usr\":\"alice\"}" keyID.usr="alice"] ` +
+		`Grant: USER=alice, KEYID=({"usr":"alice"}), CMD=(sudo echo "a]b"), REASON=certificate`
+	if got != want {
+		t.Errorf("rfc5424Message() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRFC5424Sink(t *testing.T) {
+	t.Parallel()
+	t.Run("udp", func(t *testing.T) {
+		t.Parallel()
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		sink, err := NewSink(SinkRFC5424, "udp://"+conn.LocalAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sink.Record(newTestEvent()); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+		buf := make([]byte, 65536)
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(buf[:n]); !strings.HasPrefix(got, "<86>1 2026-01-02T03:04:05.123456Z ") {
+			t.Errorf("udp message = %q", got)
+		}
+	})
+	t.Run("tcp", func(t *testing.T) {
+		t.Parallel()
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+		sink, err := NewSink(SinkRFC5424, "tcp://"+ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		received := make(chan string, 1)
+		go func() {
+			conn, err := ln.Accept()
+			if err != nil {
+				received <- ""
+				return
+			}
+			defer conn.Close()
+			data, _ := bufio.NewReader(conn).ReadString(0)
+			received <- data
+		}()
+		if err := sink.Record(newTestEvent()); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+		got := <-received
+		hostname, _ := os.Hostname()
+		message := rfc5424Message(newTestEvent(), hostname, os.Getpid())
+		if want := fmt.Sprintf("%d %s", len(message), message); got != want {
+			t.Errorf("tcp message = %q, want %q", got, want)
+		}
+	})
+}
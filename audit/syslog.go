@@ -0,0 +1,37 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package audit
+
+import (
+	"log/syslog"
+)
+
+// syslogTag is the tag of the messages of PAM-SSHCA in syslog.
+const syslogTag = "PAM_SSHCA"
+
+// syslogSink writes the text messages of the events to the local syslog with facility LOG_AUTHPRIV.
+type syslogSink struct {
+	w *syslog.Writer
+}
+
+func (s *syslogSink) Record(e *Event) error {
+	// FIXME(darwin): syslog output is lost on macOS due to
+	// https://github.com/golang/go/issues/59229
+	if s.w == nil {
+		w, err := syslog.New(syslog.LOG_AUTHPRIV, syslogTag)
+		if err != nil {
+			return err
+		}
+		s.w = w
+	}
+	switch e.Severity() {
+	case syslog.LOG_CRIT:
+		return s.w.Crit(e.String())
+	case syslog.LOG_WARNING:
+		return s.w.Warning(e.String())
+	case syslog.LOG_INFO:
+		return s.w.Info(e.String())
+	}
+	return s.w.Notice(e.String())
+}
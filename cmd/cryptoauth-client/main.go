@@ -0,0 +1,52 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+// cryptoauth-client answers the ASCII Crypto Challenge of PAM-SSHCA on the user's computer.
+// It prints the user's certificates for pasting in the server's window,
+// reads the challenge from the server, and prints the response signed by the ssh-agent.
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/theparanoids/pam-ysshca/cryptoauth"
+	sshagent "github.com/theparanoids/ysshra/agent/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func main() {
+	var principal, certFile, nonce string
+	flag.StringVar(&principal, "u", "", "principal (username) whose certificates are offered first")
+	flag.StringVar(&nonce, "n", "", "nonce of the authentication printed by the server; only its challenges are signed")
+	flag.StringVar(&certFile, "c", "", "certificate file to use if no certificate is found in ssh-agent (default ~/.ssh/*-cert.pub)")
+	flag.Parse()
+
+	var certFiles []string
+	if certFile != "" {
+		certFiles = []string{certFile}
+	} else if home, err := os.UserHomeDir(); err == nil {
+		certFiles, _ = filepath.Glob(filepath.Join(home, ".ssh", "*-cert.pub"))
+	}
+
+	sshAuthSock, err := sshagent.CheckSSHAuthSock()
+	if err != nil {
+		log.Fatalf("failed to find ssh-agent, %v", err)
+	}
+	conn, err := net.Dial("unix", sshAuthSock)
+	if err != nil {
+		log.Fatalf("failed to connect to ssh-agent, %v", err)
+	}
+	defer conn.Close()
+
+	client := cryptoauth.NewClient(agent.NewClient(conn), os.Stdin, os.Stdout)
+	if err := client.SetNonce(nonce); err != nil {
+		log.Fatalf("cryptoauth failed, %v", err)
+	}
+	if err := client.Run(principal, certFiles); err != nil {
+		log.Fatalf("cryptoauth failed, %v", err)
+	}
+}
@@ -4,37 +4,56 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"log/syslog"
+	"os"
 
 	"github.com/theparanoids/pam-ysshca/conf"
 	"github.com/theparanoids/pam-ysshca/cryptoauth"
 	"github.com/theparanoids/pam-ysshca/filter"
 	"github.com/theparanoids/pam-ysshca/pam"
-	"github.com/theparanoids/pam-ysshca/sshutils/cert"
-	"github.com/theparanoids/pam-ysshca/sshutils/key"
 )
 
 func init() {
 	pam.AddFilter("sudo-filter-regular", &filter.SudoFilterRegular{})
 
-	//  Set AuthenticateWithCryptoAuth as the fallback authentication function.
-	pam.SetNonSSHAgentAuthN(AuthenticateWithCryptoAuth)
+	//  Add AuthenticateWithCryptoAuth to the fallback authentication functions.
+	pam.AddFallbackAuthN(AuthenticateWithCryptoAuth)
 }
 
-// AuthenticateWithCryptoAuth is the fallback authentication method when the ssh-agent connection fails.
-func AuthenticateWithCryptoAuth(user string, config conf.Config, sysLogger *syslog.Writer) error {
-	caKeys := key.GetPublicKeysFromFiles(config.CAKeys)
-	if len(caKeys) == 0 {
-		return fmt.Errorf("no valid ca keys from %v", config.CAKeys)
+// AuthenticateWithCryptoAuth is the fallback authentication method on the conditions of FallbackOn directive,
+// such as when the ssh-agent connection fails.
+// The certificates are validated by the same pipeline as the ssh-agent path.
+func AuthenticateWithCryptoAuth(user string, config conf.Config, validator *pam.Validator) error {
+	auth := cryptoauth.NewAuthenticator(config, "", validator)
+	if err := auth.Authenticate(user); err != nil {
+		if errors.Is(err, cryptoauth.ErrMaxTries) {
+			return maxTriesError{err}
+		}
+		return err
 	}
+	return nil
+}
+
+// maxTriesError marks the error of cryptoauth on running out of the retries as pam.ErrMaxTries.
+type maxTriesError struct {
+	error
+}
 
-	checker := cert.CreateCertChecker(caKeys)
+func (e maxTriesError) Is(target error) bool {
+	return target == pam.ErrMaxTries
+}
 
-	// TODO: Add crypto-client arguments after we opensource sshca-client.
-	auth := cryptoauth.NewAuthenticator(config, "", checker)
-	return auth.Authenticate(user, sysLogger)
+func (e maxTriesError) Unwrap() error {
+	return e.error
 }
 
 // main is required in Go main package, though PAM-SSHCA will be compiled as a shared library.
-func main() {}
+// Compiled as an executable, it runs the commands for the administrators, such as `pam_sshca receipt verify`.
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "receipt" {
+		os.Exit(receiptCommand(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	fmt.Fprint(os.Stderr, receiptUsage)
+	os.Exit(2)
+}
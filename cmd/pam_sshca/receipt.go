@@ -0,0 +1,118 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/theparanoids/pam-ysshca/sshutils/receipt"
+	"github.com/theparanoids/ysshra/sshutils/key"
+	"golang.org/x/crypto/ssh"
+)
+
+const receiptUsage = `Usage: pam_sshca receipt verify [-ca <CA keys file>] <receipt file or directory>...
+
+Verify the receipts of the grants saved in ReceiptDir, and print the statements signed by the users.
+`
+
+// receiptCommand runs `pam_sshca receipt <subcommand>`, and returns the exit code.
+func receiptCommand(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] != "verify" {
+		fmt.Fprint(stderr, receiptUsage)
+		return 2
+	}
+	flags := flag.NewFlagSet("pam_sshca receipt verify", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, receiptUsage)
+		flags.PrintDefaults()
+	}
+	caFile := flags.String("ca", "", "file of the trusted CA public keys, which the certificates in the receipts must be signed by")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	var caKeys []ssh.PublicKey
+	if *caFile != "" {
+		data, err := os.ReadFile(*caFile)
+		if err != nil {
+			fmt.Fprintf(stderr, "failed to read CA keys: %v\n", err)
+			return 1
+		}
+		if caKeys, _, err = key.GetPublicKeysFromBytes(data); err != nil {
+			fmt.Fprintf(stderr, "failed to parse CA keys in %s: %v\n", *caFile, err)
+			return 1
+		}
+	}
+
+	paths, err := receiptPaths(flags.Args())
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return 1
+	}
+	code := 0
+	for _, path := range paths {
+		line, err := verifyReceipt(path, caKeys)
+		if err != nil {
+			fmt.Fprintf(stdout, "FAIL %s: %v\n", path, err)
+			code = 1
+			continue
+		}
+		fmt.Fprintf(stdout, "OK %s: %s\n", path, line)
+	}
+	return code
+}
+
+// receiptPaths returns the receipt files in the arguments, where a directory stands for all the receipts in it.
+func receiptPaths(args []string) ([]string, error) {
+	var paths []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			paths = append(paths, arg)
+			continue
+		}
+		files, err := filepath.Glob(filepath.Join(arg, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+		paths = append(paths, files...)
+	}
+	return paths, nil
+}
+
+// verifyReceipt verifies the receipt in the file, and returns the description of the signed statement.
+func verifyReceipt(path string, caKeys []ssh.PublicKey) (string, error) {
+	r, err := receipt.Load(path)
+	if err != nil {
+		return "", err
+	}
+	ctx, pub, err := r.Verify(caKeys)
+	if err != nil {
+		return "", err
+	}
+	signer := fmt.Sprintf("KEY=%s", ssh.FingerprintSHA256(pub))
+	if cert, ok := pub.(*ssh.Certificate); ok {
+		signer = fmt.Sprintf("KEY=%s, KEYID=(%s)", ssh.FingerprintSHA256(cert.Key), cert.KeyId)
+		if len(caKeys) == 0 {
+			signer += ", CA=unchecked"
+		}
+	}
+	return fmt.Sprintf("TIME=%s, HOST=%s, SERVICE=%s, USER=%s, TARGET=%s, %s, CMD=(%s)",
+		time.Unix(ctx.IssuedAt, 0).UTC().Format(time.RFC3339), ctx.Host, ctx.Service, ctx.User, ctx.Target, signer, ctx.Command), nil
+}
@@ -0,0 +1,220 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package conf
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/theparanoids/ysshra/keyid"
+	"golang.org/x/crypto/ssh"
+)
+
+const timeZonePrefix = "tz="
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// AllowedTime restricts when the certificates selected by the rule may be used.
+type AllowedTime struct {
+	// Principal is the wildcard pattern to select the certificates by their principals.
+	Principal string
+	// KeyIDProperty is the property/field in Key ID to select the certificates, such as "isHeadless".
+	// Please refer to the type `KeyID` in SSHRA repo.
+	KeyIDProperty string
+	// RE is the regular expression to match the key ID property.
+	RE *regexp.Regexp
+	// Weekdays lists the days of the week that the certificates may be used.
+	Weekdays [7]bool
+	// Start and End are the minutes of the day that bound the allowed time.
+	// The window crosses midnight if End is not greater than Start.
+	Start, End int
+	// Location is the time zone of the window.
+	Location *time.Location
+}
+
+// newAllowedTime parses the AllowedTimes directive in format
+// `selector weekdays hours [tz=zone] [service[,service...]]`,
+// and returns the rule with the list of PAM services that it applies to.
+// The selector is "*", `principal=<pattern>` or `<keyIDProperty>=<regex>`.
+// The weekdays are "*" or a comma-separated list of days or ranges, such as "Mon-Fri,Sun".
+// The hours are "*" or a range such as "09:00-17:00".
+func newAllowedTime(ruleStr string) (AllowedTime, []string, error) {
+	fields := strings.Fields(ruleStr)
+	if len(fields) < 3 || len(fields) > 5 {
+		return AllowedTime{}, nil, fmt.Errorf("expected a selector, weekdays, hours, an optional time zone and an optional list of services, got %d fields", len(fields))
+	}
+
+	rule := AllowedTime{Location: time.Local}
+	if fields[0] != "*" {
+		sep := strings.Index(fields[0], "=")
+		if sep <= 0 {
+			return AllowedTime{}, nil, fmt.Errorf("invalid selector %q", fields[0])
+		}
+		key, value := fields[0][:sep], fields[0][sep+1:]
+		if key == "principal" {
+			rule.Principal = value
+		} else {
+			re, err := regexp.Compile(value)
+			if err != nil {
+				return AllowedTime{}, nil, err
+			}
+			rule.KeyIDProperty = key
+			rule.RE = re
+		}
+	}
+
+	if err := rule.parseWeekdays(fields[1]); err != nil {
+		return AllowedTime{}, nil, err
+	}
+	if err := rule.parseHours(fields[2]); err != nil {
+		return AllowedTime{}, nil, err
+	}
+
+	var services []string
+	for _, field := range fields[3:] {
+		if strings.HasPrefix(field, timeZonePrefix) {
+			loc, err := time.LoadLocation(strings.TrimPrefix(field, timeZonePrefix))
+			if err != nil {
+				return AllowedTime{}, nil, err
+			}
+			rule.Location = loc
+			continue
+		}
+		if services != nil {
+			return AllowedTime{}, nil, fmt.Errorf("unexpected field %q", field)
+		}
+		services = strings.Split(field, ",")
+	}
+	return rule, services, nil
+}
+
+func (r *AllowedTime) parseWeekdays(str string) error {
+	if str == "*" {
+		for i := range r.Weekdays {
+			r.Weekdays[i] = true
+		}
+		return nil
+	}
+	for _, days := range strings.Split(str, ",") {
+		first, last, isRange := strings.Cut(strings.ToLower(days), "-")
+		from, ok := weekdays[first]
+		if !ok {
+			return fmt.Errorf("invalid weekday %q", first)
+		}
+		to := from
+		if isRange {
+			if to, ok = weekdays[last]; !ok {
+				return fmt.Errorf("invalid weekday %q", last)
+			}
+		}
+		for d := from; ; d = (d + 1) % 7 {
+			r.Weekdays[d] = true
+			if d == to {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (r *AllowedTime) parseHours(str string) error {
+	if str == "*" {
+		r.Start, r.End = 0, 0
+		return nil
+	}
+	start, end, ok := strings.Cut(str, "-")
+	if !ok {
+		return fmt.Errorf("invalid hours %q", str)
+	}
+	var err error
+	if r.Start, err = parseMinutes(start); err != nil {
+		return err
+	}
+	if r.End, err = parseMinutes(end); err != nil {
+		return err
+	}
+	return nil
+}
+
+// parseMinutes parses the time of day in format HH:MM, and returns the minutes since midnight.
+func parseMinutes(str string) (int, error) {
+	hour, minute, ok := strings.Cut(str, ":")
+	if !ok {
+		return 0, fmt.Errorf("invalid time %q", str)
+	}
+	h, err := strconv.Atoi(hour)
+	if err != nil || h < 0 || h > 24 {
+		return 0, fmt.Errorf("invalid time %q", str)
+	}
+	m, err := strconv.Atoi(minute)
+	if err != nil || m < 0 || m > 59 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("invalid time %q", str)
+	}
+	return h*60 + m, nil
+}
+
+// Selects returns true if the rule applies to the certificate.
+// kid can be nil if the Key ID of the certificate is invalid, in which case rules on Key ID don't apply.
+func (r AllowedTime) Selects(cert *ssh.Certificate, kid *keyid.KeyID) bool {
+	switch {
+	case r.Principal != "":
+		for _, principal := range cert.ValidPrincipals {
+			if matchWildcard(principal, r.Principal) {
+				return true
+			}
+		}
+		return false
+	case r.KeyIDProperty != "":
+		return kid != nil && r.RE.MatchString(kid.GetProperty(r.KeyIDProperty))
+	}
+	return true
+}
+
+// Allows returns true if t is in the allowed time of the rule.
+// For a window crossing midnight, the weekday is the day that the window starts.
+func (r AllowedTime) Allows(t time.Time) bool {
+	t = t.In(r.Location)
+	minutes := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+	switch {
+	case r.Start < r.End:
+		return r.Weekdays[day] && minutes >= r.Start && minutes < r.End
+	case minutes >= r.Start:
+		return r.Weekdays[day]
+	case minutes < r.End:
+		return r.Weekdays[(day+6)%7]
+	}
+	return false
+}
+
+// CheckAllowedTimes returns nil if no rule applies to the certificate,
+// or if t is in the allowed time of any rule that applies to the certificate.
+func CheckAllowedTimes(cert *ssh.Certificate, rules []AllowedTime, t time.Time) error {
+	kid, _ := keyid.Unmarshal(cert.KeyId)
+	selected := false
+	for _, rule := range rules {
+		if !rule.Selects(cert, kid) {
+			continue
+		}
+		if rule.Allows(t) {
+			return nil
+		}
+		selected = true
+	}
+	if selected {
+		return fmt.Errorf("certificate is not allowed at %s", t.Format(time.RFC1123))
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package conf
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/theparanoids/ysshra/keyid"
+	"golang.org/x/crypto/ssh"
+)
+
+func Test_newAllowedTime(t *testing.T) {
+	weekdays := [7]bool{false, true, true, true, true, true, false}
+	tests := []struct {
+		name         string
+		ruleStr      string
+		want         AllowedTime
+		wantServices []string
+		wantErr      bool
+	}{
+		{
+			name:    "all certificates",
+			ruleStr: "* Mon-Fri 09:00-17:00",
+			want:    AllowedTime{Weekdays: weekdays, Start: 540, End: 1020, Location: time.Local},
+		},
+		{
+			name:         "principal with time zone and services",
+			ruleStr:      "principal=oncall-* Sat,Sun * tz=UTC sudo,su",
+			want:         AllowedTime{Principal: "oncall-*", Weekdays: [7]bool{true, false, false, false, false, false, true}, Location: time.UTC},
+			wantServices: []string{"sudo", "su"},
+		},
+		{
+			name:    "key ID property with wrapping weekdays",
+			ruleStr: "isHeadless=true Fri-Mon 22:00-06:00",
+			want: AllowedTime{
+				KeyIDProperty: "isHeadless",
+				RE:            regexp.MustCompile("true"),
+				Weekdays:      [7]bool{true, true, false, false, false, true, true},
+				Start:         1320,
+				End:           360,
+				Location:      time.Local,
+			},
+		},
+		{
+			name:    "invalid weekday",
+			ruleStr: "* Mon-Fry 09:00-17:00",
+			wantErr: true,
+		},
+		{
+			name:    "invalid hours",
+			ruleStr: "* Mon 09:00-25:00",
+			wantErr: true,
+		},
+		{
+			name:    "invalid time zone",
+			ruleStr: "* Mon * tz=Mars/Olympus",
+			wantErr: true,
+		},
+		{
+			name:    "invalid selector",
+			ruleStr: "headless Mon *",
+			wantErr: true,
+		},
+		{
+			name:    "too many service lists",
+			ruleStr: "* Mon * sudo su",
+			wantErr: true,
+		},
+		{
+			name:    "missing hours",
+			ruleStr: "* Mon",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, services, err := newAllowedTime(tt.ruleStr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newAllowedTime() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newAllowedTime() got = %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(services, tt.wantServices) {
+				t.Errorf("newAllowedTime() services = %v, want %v", services, tt.wantServices)
+			}
+		})
+	}
+}
+
+func TestCheckAllowedTimes(t *testing.T) {
+	mustRule := func(ruleStr string) AllowedTime {
+		rule, _, err := newAllowedTime(ruleStr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rule
+	}
+	headlessKeyID, err := (&keyid.KeyID{IsHeadless: true}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	headless := &ssh.Certificate{KeyId: headlessKeyID, ValidPrincipals: []string{"alice"}}
+	interactive := &ssh.Certificate{KeyId: "{}", ValidPrincipals: []string{"oncall-bob"}}
+
+	// 2026-10-16 is a Friday.
+	rules := []AllowedTime{
+		mustRule("isHeadless=true Fri 22:00-06:00 tz=UTC"),
+		mustRule("principal=oncall-* Mon-Fri 09:00-17:00 tz=UTC"),
+		mustRule("principal=oncall-* Sat,Sun * tz=UTC"),
+	}
+	tests := []struct {
+		name    string
+		cert    *ssh.Certificate
+		t       time.Time
+		wantErr bool
+	}{
+		{
+			name: "in maintenance window",
+			cert: headless,
+			t:    time.Date(2026, 10, 16, 23, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "maintenance window after midnight",
+			cert: headless,
+			t:    time.Date(2026, 10, 17, 5, 59, 0, 0, time.UTC),
+		},
+		{
+			name:    "maintenance window ended",
+			cert:    headless,
+			t:       time.Date(2026, 10, 17, 6, 0, 0, 0, time.UTC),
+			wantErr: true,
+		},
+		{
+			name:    "maintenance window on another day",
+			cert:    headless,
+			t:       time.Date(2026, 10, 15, 23, 0, 0, 0, time.UTC),
+			wantErr: true,
+		},
+		{
+			name: "window in another time zone",
+			cert: headless,
+			t:    time.Date(2026, 10, 16, 16, 0, 0, 0, time.FixedZone("PDT", -7*3600)),
+		},
+		{
+			name: "business hours",
+			cert: interactive,
+			t:    time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "any rule allows",
+			cert: interactive,
+			t:    time.Date(2026, 10, 18, 3, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "out of business hours",
+			cert:    interactive,
+			t:       time.Date(2026, 10, 16, 17, 0, 0, 0, time.UTC),
+			wantErr: true,
+		},
+		{
+			name: "no rule selects the certificate",
+			cert: &ssh.Certificate{KeyId: "{}", ValidPrincipals: []string{"carol"}},
+			t:    time.Date(2026, 10, 16, 3, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckAllowedTimes(tt.cert, rules, tt.t); (err != nil) != tt.wantErr {
+				t.Errorf("CheckAllowedTimes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package conf
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const regexPrefix = "re:"
+
+// CommandPolicy requires stronger credentials to authenticate the commands that match the policy.
+type CommandPolicy struct {
+	// Glob is the wildcard pattern to match the command, where '*' matches any sequence of characters
+	// and '?' matches any single character.
+	Glob string
+	// RE is the regular expression to match the command. It is used instead of Glob if it is not nil.
+	RE *regexp.Regexp
+	// KeyIDRules are the rules that certificates must pass to authenticate the command.
+	KeyIDRules []KeyIDRule
+	// NoStaticKeys specifies whether static keys are forbidden to authenticate the command.
+	NoStaticKeys bool
+	// Confirm specifies whether the user must explicitly confirm the command before authentication.
+	Confirm bool
+}
+
+// commandPolicyRequirements maps the requirements in CommandPolicy directive to the Require rules on Key ID.
+// The rules apply to certificates only, so these requirements forbid static keys as well.
+var commandPolicyRequirements = map[string]KeyIDRule{
+	"hwkey":       {Field: "isHWKey", Op: "=", Value: "true"},
+	"touch":       {Field: "touchPolicy", Op: ">=", Value: "2"},
+	"no-headless": {Field: "isHeadless", Op: "=", Value: "false"},
+}
+
+// newCommandPolicy parses the CommandPolicy directive in format `requirement[,requirement...] pattern`.
+// The requirements are hwkey, touch, no-headless, no-static-keys and confirm.
+// The requirements on the credentials (hwkey, touch and no-headless) imply no-static-keys.
+// The pattern is a wildcard pattern, or a regular expression with prefix "re:".
+func newCommandPolicy(policyStr string) (CommandPolicy, error) {
+	policyStr = strings.TrimSpace(policyStr)
+	sep := strings.IndexAny(policyStr, " \t")
+	if sep < 0 {
+		return CommandPolicy{}, fmt.Errorf("expected a list of requirements and a command pattern")
+	}
+
+	var policy CommandPolicy
+	for _, req := range strings.Split(policyStr[:sep], ",") {
+		switch req {
+		case "no-static-keys":
+			policy.NoStaticKeys = true
+		case "confirm":
+			policy.Confirm = true
+		default:
+			rule, ok := commandPolicyRequirements[req]
+			if !ok {
+				return CommandPolicy{}, fmt.Errorf("unsupported requirement %q", req)
+			}
+			policy.KeyIDRules = append(policy.KeyIDRules, rule)
+			policy.NoStaticKeys = true
+		}
+	}
+
+	pattern := strings.TrimSpace(policyStr[sep+1:])
+	if strings.HasPrefix(pattern, regexPrefix) {
+		re, err := regexp.Compile(strings.TrimPrefix(pattern, regexPrefix))
+		if err != nil {
+			return CommandPolicy{}, err
+		}
+		policy.RE = re
+	} else {
+		policy.Glob = strings.Join(strings.Fields(pattern), " ")
+	}
+	return policy, nil
+}
+
+// Match returns true if the policy matches the command line of the PAM application.
+// The first argument of cmd (e.g. "sudo") is not matched.
+// A regular expression is matched against the rest of the arguments.
+// A wildcard pattern is matched against the arguments starting at any position, so that
+// the options of the PAM application (e.g. "sudo -u root") don't bypass the policy,
+// and the program is matched either by its path or by its base name.
+func (p CommandPolicy) Match(cmd string) bool {
+	args := strings.Fields(cmd)
+	if len(args) < 2 {
+		return false
+	}
+	args = args[1:]
+	if p.RE != nil {
+		return p.RE.MatchString(strings.Join(args, " "))
+	}
+	for i := range args {
+		candidate := strings.Join(args[i:], " ")
+		if matchWildcard(candidate, p.Glob) {
+			return true
+		}
+		base := strings.Join(append([]string{path.Base(args[i])}, args[i+1:]...), " ")
+		if matchWildcard(base, p.Glob) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package conf
+
+import (
+	"testing"
+)
+
+func Test_newCommandPolicy(t *testing.T) {
+	tests := []struct {
+		name             string
+		policyStr        string
+		wantRules        int
+		wantNoStaticKeys bool
+		wantConfirm      bool
+		wantErr          bool
+	}{
+		{
+			name:             "all requirements",
+			policyStr:        "hwkey,touch,no-headless,no-static-keys,confirm rm *",
+			wantRules:        3,
+			wantNoStaticKeys: true,
+			wantConfirm:      true,
+		},
+		{
+			name:             "regex",
+			policyStr:        "touch re:^systemctl (stop|restart) ",
+			wantRules:        1,
+			wantNoStaticKeys: true,
+		},
+		{
+			name:        "confirm only",
+			policyStr:   "confirm reboot",
+			wantConfirm: true,
+		},
+		{
+			name:      "missing pattern",
+			policyStr: "touch",
+			wantErr:   true,
+		},
+		{
+			name:      "unsupported requirement",
+			policyStr: "fingerprint rm *",
+			wantErr:   true,
+		},
+		{
+			name:      "invalid regex",
+			policyStr: "touch re:(",
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newCommandPolicy(tt.policyStr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newCommandPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got.KeyIDRules) != tt.wantRules || got.NoStaticKeys != tt.wantNoStaticKeys || got.Confirm != tt.wantConfirm {
+				t.Errorf("newCommandPolicy() = %+v", got)
+			}
+		})
+	}
+}
+
+func TestCommandPolicy_Match(t *testing.T) {
+	tests := []struct {
+		name      string
+		policyStr string
+		cmd       string
+		want      bool
+	}{
+		{name: "glob", policyStr: "touch rm *", cmd: "sudo rm -rf /", want: true},
+		{name: "glob with program path", policyStr: "touch rm *", cmd: "sudo /bin/rm -rf /", want: true},
+		{name: "glob after sudo options", policyStr: "touch rm *", cmd: "sudo -u root rm -rf /", want: true},
+		{name: "glob mismatch", policyStr: "touch rm *", cmd: "sudo ls -l", want: false},
+		{name: "glob doesn't match sudo itself", policyStr: "touch sudo*", cmd: "sudo ls", want: false},
+		{name: "regex", policyStr: "touch re:^systemctl (stop|restart) ", cmd: "sudo systemctl stop nginx", want: true},
+		{name: "regex mismatch", policyStr: "touch re:^systemctl (stop|restart) ", cmd: "sudo systemctl status nginx", want: false},
+		{name: "no arguments", policyStr: "touch *", cmd: "sudo", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := newCommandPolicy(tt.policyStr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := policy.Match(tt.cmd); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
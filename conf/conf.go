@@ -7,9 +7,12 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"os/user"
 	"regexp"
 	"strings"
+	"time"
 
+	"github.com/theparanoids/pam-ysshca/audit"
 	"github.com/theparanoids/pam-ysshca/msg"
 )
 
@@ -22,10 +25,36 @@ type Config struct {
 	// StaticKeys specifies the file paths to authorized keys.
 	// The path is either an absolute path or one relative to the current user's home directory.
 	StaticKeys []string
+	// AuthorizedKeysCommand is the program, followed by its arguments, that prints additional
+	// authorized keys for the current user in OpenSSH AUTHORIZED_KEYS format.
+	AuthorizedKeysCommand []string
 	// AllowCertificate specifies whether PAM-SSHCA should check certificates that signed by the trust CAs in CAKeys.
 	AllowCertificate bool
 	// SupportedCriticalOptions lists the CriticalOptions of SSH certs that PAM-SSHCA allows.
 	SupportedCriticalOptions []string
+	// MaxCertLifetime is the maximum validity period of SSH certs. Zero means no limit.
+	MaxCertLifetime time.Duration
+	// MinRemainingValidity is the minimum time that SSH certs must stay valid after authentication. Zero means no limit.
+	MinRemainingValidity time.Duration
+	// ClockSkew is the tolerance of the difference between the clocks of the local host and the CA.
+	ClockSkew time.Duration
+	// RequiredExtensions lists the extensions that SSH certs must have for the current PAM service.
+	RequiredExtensions []RequiredExtension
+	// KeyIDRules lists the Require and Deny rules on Key ID of SSH certs for the current PAM service.
+	KeyIDRules []KeyIDRule
+	// CommandPolicies lists the policies that require stronger credentials for the matching commands.
+	CommandPolicies []CommandPolicy
+	// AllowFirefighter specifies whether PAM-SSHCA accepts firefighter (break-glass) certificates for the current PAM service.
+	AllowFirefighter bool
+	// FirefighterNotify lists the programs or webhook URLs to notify when a firefighter certificate is used.
+	FirefighterNotify []string
+	// FirefighterNotifyRequired specifies whether the access by a firefighter certificate is denied
+	// if any notifier of FirefighterNotify fails.
+	FirefighterNotifyRequired bool
+	// AllowedTimes lists the rules that restrict when the certificates may be used for the current PAM service.
+	AllowedTimes []AllowedTime
+	// HostTags lists the tags of the local host, which are matched against the host tags that certificates are bound to.
+	HostTags []string
 	// CAKeys specified the paths of the trust CA public keys.
 	CAKeys []string
 	// authorizedPrincipalPrefix is the list of prefix string that tells PAM-SSHCA to accept additional principals
@@ -33,16 +62,132 @@ type Config struct {
 	// For example, authorized principal prefix "screwdriver:" will allow PAM-SSHCA to accept the authN from
 	// the screwdriver tool to assume "user" by presenting a valid cert with principal "screwdriver:user".
 	authorizedPrincipalPrefix []string
+	// authorizedGroupPrincipals is the list of principal templates that tells PAM-SSHCA to accept additional principals
+	// for the Unix groups of the user. "%g" in the template is replaced by each group name of the user.
+	// For example, template "group:%g" will allow PAM-SSHCA to accept a valid cert with principal "group:sre"
+	// if the user is a member of group "sre".
+	authorizedGroupPrincipals []string
 	// authorizedPrincipalFiles specifies the list of additional principal name files that are accepted for authentication.
 	authorizedPrincipalFiles []string
+	// PrincipalMaps is the list of rules to map the principals of certificates to authorized principals.
+	PrincipalMaps []PrincipalMap
 	// Prompters is the list of prompters to prompt messages to users during authentication.
 	Prompters []Prompter
+	// FallbackOn lists the conditions on which the fallback authentication methods run, such as FallbackNoAgent.
+	FallbackOn []string
+	// CryptoAuthQRCode specifies whether cryptoauth renders the client command and the challenge as QR codes
+	// in addition to the plain text.
+	CryptoAuthQRCode bool
+	// CryptoAuthInputRetries is the number of retries in cryptoauth after the user pastes malformed input.
+	CryptoAuthInputRetries int
+	// CryptoAuthSignatureRetries is the number of new challenges in cryptoauth after a challenge response
+	// fails the verification or the challenge expires.
+	CryptoAuthSignatureRetries int
+	// CryptoAuthChallengeTTL is the time before a challenge issued by cryptoauth expires.
+	CryptoAuthChallengeTTL time.Duration
+	// CryptoAuthTimeout is the overall time that cryptoauth waits for the input from the user.
+	CryptoAuthTimeout time.Duration
+	// CryptoAuthClientCommand is the template of the client command that cryptoauth asks the user to run,
+	// with the tokens in CryptoAuthClientCommandTokens. Empty means the default command.
+	CryptoAuthClientCommand string
+	// CryptoAuthClientPrompt, CryptoAuthChallengePrompt and CryptoAuthResponsePrompt replace the messages
+	// of cryptoauth that ask the user to run the client command, to copy the challenge, and to paste the response.
+	// Empty means the default message.
+	CryptoAuthClientPrompt    string
+	CryptoAuthChallengePrompt string
+	CryptoAuthResponsePrompt  string
+	// CryptoAuthHelpURL is the URL of the site's documentation of cryptoauth, which is printed with the client command.
+	CryptoAuthHelpURL string
+	// ReceiptDir is the directory where the signed challenges of the grants are saved as receipts.
+	// Empty disables the receipts.
+	ReceiptDir string
+	// AuditSinks lists the sinks of the audit events. The default is the local syslog.
+	AuditSinks []AuditSink
+}
+
+// AuditSink is a sink of the audit events, see audit.NewSink.
+type AuditSink struct {
+	// Type is the type of the sink, such as audit.SinkJSON.
+	Type string
+	// Target is the file, the socket or the server that the sink writes to.
+	Target string
+}
+
+func newAuditSink(sinkStr string) (AuditSink, error) {
+	fields := strings.Fields(sinkStr)
+	if len(fields) == 0 || len(fields) > 2 {
+		return AuditSink{}, fmt.Errorf("expected a sink type and an optional target, got %d fields", len(fields))
+	}
+	sink := AuditSink{Type: fields[0]}
+	if len(fields) == 2 {
+		sink.Target = fields[1]
+	}
+	if _, err := audit.NewSink(sink.Type, sink.Target); err != nil {
+		return AuditSink{}, err
+	}
+	return sink, nil
+}
+
+// CryptoAuthClientCommandTokens are the tokens in CryptoAuthClientCommand: %u is the user to authenticate,
+// %h is the hostname, %s is the PAM service, %n is the hex encoded nonce of the authentication,
+// which starts the nonces of its challenges, and %% is a literal %.
+var CryptoAuthClientCommandTokens = []string{"%u", "%h", "%s", "%n", "%%"}
+
+// The conditions of FallbackOn.
+const (
+	// FallbackNoAgent is the condition that the ssh-agent is not reachable.
+	FallbackNoAgent = "no-agent"
+	// FallbackNoValidIdentity is the condition that the ssh-agent has no valid static key or certificate.
+	FallbackNoValidIdentity = "no-valid-identity"
+	// FallbackChallengeFailed is the condition that the challenges of all the valid identities in the ssh-agent failed.
+	FallbackChallengeFailed = "challenge-failed"
+	// FallbackNever disables the fallback authentication methods.
+	FallbackNever = "never"
+)
+
+// ShouldFallback returns true if the fallback authentication methods should run on the condition.
+func (c *Config) ShouldFallback(condition string) bool {
+	for _, on := range c.FallbackOn {
+		if on == condition {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupGroupNames returns the names of the Unix groups that the user belongs to.
+var lookupGroupNames = func(username string) ([]string, error) {
+	u, err := user.Lookup(username)
+	if err != nil {
+		return nil, err
+	}
+	gids, err := u.GroupIds()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, gid := range gids {
+		g, err := user.LookupGroupId(gid)
+		if err != nil {
+			msg.Printlf(msg.DEBUG, "Failed to look up group %s: %v", gid, err)
+			continue
+		}
+		names = append(names, g.Name)
+	}
+	return names, nil
 }
 
 func defaultConfig() Config {
 	return Config{
-		AllowStaticKeys:  true,
-		AllowCertificate: false,
+		AllowStaticKeys:            true,
+		AllowCertificate:           false,
+		AllowFirefighter:           true,
+		FallbackOn:                 []string{FallbackNoAgent},
+		CryptoAuthInputRetries:     2,
+		CryptoAuthSignatureRetries: 1,
+		CryptoAuthChallengeTTL:     5 * time.Minute,
+		CryptoAuthTimeout:          10 * time.Minute,
+		AuditSinks:                 []AuditSink{{Type: audit.SinkSyslog}},
 	}
 }
 
@@ -71,14 +216,71 @@ func newPrompter(promptStr string) (Prompter, error) {
 	}, nil
 }
 
+// PrincipalMap maps a principal of certificates to an authorized principal.
+type PrincipalMap struct {
+	// RE is the regular expression to match the whole principal of a certificate.
+	RE *regexp.Regexp
+	// Replacement is the template of the mapped principal.
+	// $1 (or ${1}) in the template is replaced by the text of the first capture group in RE, and so on.
+	Replacement string
+}
+
+func newPrincipalMap(mapStr string) (PrincipalMap, error) {
+	fields := strings.Fields(mapStr)
+	if len(fields) != 2 {
+		return PrincipalMap{}, fmt.Errorf("expected a regular expression and a replacement, got %d fields", len(fields))
+	}
+	re, err := regexp.Compile("^(?:" + fields[0] + ")$")
+	if err != nil {
+		return PrincipalMap{}, err
+	}
+	return PrincipalMap{
+		RE:          re,
+		Replacement: fields[1],
+	}, nil
+}
+
+// Map returns the mapped principal if RE matches the given principal.
+func (m PrincipalMap) Map(principal string) (string, bool) {
+	match := m.RE.FindStringSubmatchIndex(principal)
+	if match == nil {
+		return "", false
+	}
+	return string(m.RE.ExpandString(nil, m.Replacement, principal, match)), true
+}
+
+// String returns the rule in the same format as the PrincipalMap directive.
+func (m PrincipalMap) String() string {
+	re := strings.TrimSuffix(strings.TrimPrefix(m.RE.String(), "^(?:"), ")$")
+	return fmt.Sprintf("%s %s", re, m.Replacement)
+}
+
 // AuthorizedPrincipals returns the authorized principals for the given username.
-func (c *Config) AuthorizedPrincipals(username string) (principals map[string]bool, err error) {
-	principals = make(map[string]bool)
+// Each principal maps to the options of the entries that authorize it.
+// The principals that are authorized without restrictions have a zero PrincipalOptions.
+func (c *Config) AuthorizedPrincipals(username string) (principals map[string][]PrincipalOptions, err error) {
+	principals = make(map[string][]PrincipalOptions)
 
-	principals[username] = true
+	principals[username] = []PrincipalOptions{{}}
 	// Add "prefix:$username" to authorizedPrincipalFiles, such as "pogo:example_user".
 	for _, prefix := range c.authorizedPrincipalPrefix {
-		principals[fmt.Sprintf("%s%s", prefix, username)] = true
+		principal := fmt.Sprintf("%s%s", prefix, username)
+		principals[principal] = append(principals[principal], PrincipalOptions{})
+	}
+
+	// Add the group principals, such as "group:sre".
+	// If looking up the groups fails, ignore and continue.
+	if len(c.authorizedGroupPrincipals) != 0 {
+		groups, err := lookupGroupNames(username)
+		if err != nil {
+			msg.Printlf(msg.WARN, "Failed to look up groups of user %s: %v", username, err)
+		}
+		for _, template := range c.authorizedGroupPrincipals {
+			for _, group := range groups {
+				principal := strings.ReplaceAll(template, "%g", group)
+				principals[principal] = append(principals[principal], PrincipalOptions{})
+			}
+		}
 	}
 
 	for _, authorizedPrincipalsFile := range c.authorizedPrincipalFiles {
@@ -88,14 +290,16 @@ func (c *Config) AuthorizedPrincipals(username string) (principals map[string]bo
 		}
 
 		lines := bytes.Split(data, []byte("\n"))
-		for _, line := range lines {
-			fields := bytes.Fields(line)
-			if len(fields) == 0 || strings.HasPrefix(string(fields[0]), "#") {
+		for i, line := range lines {
+			principal, opts, err := parsePrincipalsLine(string(line))
+			if err != nil {
+				msg.Printlf(msg.WARN, "%s:%d: invalid principal entry, skipping: %v", authorizedPrincipalsFile, i+1, err)
+				continue
+			}
+			if principal == "" {
 				continue
 			}
-			// Last field is the principal name.
-			principal := string(fields[len(fields)-1])
-			principals[principal] = true
+			principals[principal] = append(principals[principal], opts)
 		}
 	}
 	msg.Printlf(msg.DEBUG, "Authorized principals: %v", principals)
@@ -6,6 +6,7 @@ package conf
 import (
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestConfig_AuthorizedPrincipals(t *testing.T) {
@@ -13,8 +14,10 @@ func TestConfig_AuthorizedPrincipals(t *testing.T) {
 		name                      string
 		username                  string
 		authorizedPrincipalPrefix []string
+		authorizedGroupPrincipals []string
+		groups                    []string
 		authorizedPrincipalFiles  []string
-		wantPrincipals            map[string]bool
+		wantPrincipals            map[string][]PrincipalOptions
 		wantErr                   bool
 	}{
 		{
@@ -22,21 +25,58 @@ func TestConfig_AuthorizedPrincipals(t *testing.T) {
 			username:                  "user1",
 			authorizedPrincipalPrefix: []string{"screwdriver"},
 			authorizedPrincipalFiles:  []string{"./testdata/additional_authorized_principals_user1"},
-			wantPrincipals:            map[string]bool{"screwdriveruser1": true, "user1": true, "user1:111": true, "user1:touch": true, "user2:222": true},
+			wantPrincipals: map[string][]PrincipalOptions{
+				"screwdriveruser1": {{}},
+				"user1":            {{}, {}},
+				"user1:111":        {{}},
+				"user1:touch":      {{}},
+				"user2:222":        {{}},
+			},
+		},
+		{
+			name:                      "group principals",
+			username:                  "user1",
+			authorizedGroupPrincipals: []string{"group:%g", "role-%g"},
+			groups:                    []string{"sre", "dev"},
+			wantPrincipals: map[string][]PrincipalOptions{
+				"user1":     {{}},
+				"group:sre": {{}},
+				"group:dev": {{}},
+				"role-sre":  {{}},
+				"role-dev":  {{}},
+			},
+		},
+		{
+			name:                     "principals with options",
+			username:                 "user1",
+			authorizedPrincipalFiles: []string{"./testdata/authorized_principals_with_options"},
+			wantPrincipals: map[string][]PrincipalOptions{
+				"user1": {{}},
+				"user1:net": {
+					{From: []string{"10.0.0.0/8", "!10.1.0.0/16"}},
+				},
+				"user1:cmd": {
+					{Command: `systemctl restart "nginx"`, ExpiryTime: time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)},
+				},
+			},
 		},
 		{
 			name:                      "happy path",
 			username:                  "user1",
 			authorizedPrincipalPrefix: []string{"screwdriver"},
 			authorizedPrincipalFiles:  []string{"invalid file path"},
-			wantPrincipals:            map[string]bool{"screwdriveruser1": true, "user1": true},
+			wantPrincipals:            map[string][]PrincipalOptions{"screwdriveruser1": {{}}, "user1": {{}}},
 			wantErr:                   true,
 		},
 	}
+	origLookupGroupNames := lookupGroupNames
+	defer func() { lookupGroupNames = origLookupGroupNames }()
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			lookupGroupNames = func(string) ([]string, error) { return tt.groups, nil }
 			c := &Config{
 				authorizedPrincipalPrefix: tt.authorizedPrincipalPrefix,
+				authorizedGroupPrincipals: tt.authorizedGroupPrincipals,
 				authorizedPrincipalFiles:  tt.authorizedPrincipalFiles,
 			}
 			gotPrincipals, err := c.AuthorizedPrincipals(tt.username)
@@ -50,3 +90,66 @@ func TestConfig_AuthorizedPrincipals(t *testing.T) {
 		})
 	}
 }
+
+func TestPrincipalMap_Map(t *testing.T) {
+	tests := []struct {
+		name       string
+		mapStr     string
+		principal  string
+		wantMapped string
+		wantOK     bool
+		wantErr    bool
+	}{
+		{
+			name:       "strip realm",
+			mapStr:     `^(.*)@CORP\.EXAMPLE$ $1`,
+			principal:  "alice@CORP.EXAMPLE",
+			wantMapped: "alice",
+			wantOK:     true,
+		},
+		{
+			name:       "service account",
+			mapStr:     `sd:pipeline-(\d+) svc-deploy`,
+			principal:  "sd:pipeline-123",
+			wantMapped: "svc-deploy",
+			wantOK:     true,
+		},
+		{
+			name:       "capture group in template",
+			mapStr:     `sd:pipeline-(\d+) pipeline${1}`,
+			principal:  "sd:pipeline-123",
+			wantMapped: "pipeline123",
+			wantOK:     true,
+		},
+		{
+			name:      "partial match is rejected",
+			mapStr:    `sd:pipeline-(\d+) svc-deploy`,
+			principal: "evil-sd:pipeline-123",
+		},
+		{
+			name:    "missing replacement",
+			mapStr:  `sd:pipeline-(\d+)`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid regular expression",
+			mapStr:  `sd:pipeline-(\d+ svc-deploy`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := newPrincipalMap(tt.mapStr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newPrincipalMap() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			mapped, ok := m.Map(tt.principal)
+			if ok != tt.wantOK || mapped != tt.wantMapped {
+				t.Errorf("Map() = (%q, %v), want (%q, %v)", mapped, ok, tt.wantMapped, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package conf
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// RequiredExtension is an extension that SSH certs must have.
+type RequiredExtension struct {
+	// Name is the name of the extension, such as "permit-sudo@ysshca".
+	Name string
+	// Value is the value that the extension must have if MatchValue is true.
+	Value string
+	// MatchValue specifies whether the value of the extension must match Value.
+	MatchValue bool
+}
+
+// newRequiredExtension parses the RequiredExtension directive in format `name[=value] [service[,service...]]`,
+// and returns the extension with the list of PAM services that it applies to.
+func newRequiredExtension(extStr string) (RequiredExtension, []string, error) {
+	fields := strings.Fields(extStr)
+	if len(fields) == 0 || len(fields) > 2 {
+		return RequiredExtension{}, nil, fmt.Errorf("expected an extension and an optional list of services, got %d fields", len(fields))
+	}
+
+	ext := RequiredExtension{Name: fields[0]}
+	if sep := strings.Index(fields[0], "="); sep >= 0 {
+		ext = RequiredExtension{
+			Name:       fields[0][:sep],
+			Value:      fields[0][sep+1:],
+			MatchValue: true,
+		}
+	}
+	if ext.Name == "" {
+		return RequiredExtension{}, nil, fmt.Errorf("empty extension name")
+	}
+
+	var services []string
+	if len(fields) == 2 {
+		services = strings.Split(fields[1], ",")
+	}
+	return ext, services, nil
+}
+
+// String returns the extension in the same format as the RequiredExtension directive.
+func (e RequiredExtension) String() string {
+	if e.MatchValue {
+		return fmt.Sprintf("%s=%s", e.Name, e.Value)
+	}
+	return e.Name
+}
+
+// CheckRequiredExtensions returns nil if the certificate has all the required extensions.
+func CheckRequiredExtensions(cert *ssh.Certificate, required []RequiredExtension) error {
+	for _, ext := range required {
+		value, ok := cert.Extensions[ext.Name]
+		if !ok {
+			return fmt.Errorf("missing required extension %s", ext.Name)
+		}
+		if ext.MatchValue && value != ext.Value {
+			return fmt.Errorf("extension %s has value %q, expected %q", ext.Name, value, ext.Value)
+		}
+	}
+	return nil
+}
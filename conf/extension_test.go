@@ -0,0 +1,106 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package conf
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func Test_newRequiredExtension(t *testing.T) {
+	tests := []struct {
+		name         string
+		extStr       string
+		want         RequiredExtension
+		wantServices []string
+		wantErr      bool
+	}{
+		{
+			name:   "extension only",
+			extStr: "permit-sudo@ysshca",
+			want:   RequiredExtension{Name: "permit-sudo@ysshca"},
+		},
+		{
+			name:         "extension with value and services",
+			extStr:       "login@ysshca=alice sudo,su",
+			want:         RequiredExtension{Name: "login@ysshca", Value: "alice", MatchValue: true},
+			wantServices: []string{"sudo", "su"},
+		},
+		{
+			name:   "extension with empty value",
+			extStr: "login@ysshca=",
+			want:   RequiredExtension{Name: "login@ysshca", MatchValue: true},
+		},
+		{
+			name:    "empty name",
+			extStr:  "=alice",
+			wantErr: true,
+		},
+		{
+			name:    "too many fields",
+			extStr:  "permit-sudo@ysshca sudo su",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, services, err := newRequiredExtension(tt.extStr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newRequiredExtension() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newRequiredExtension() got = %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(services, tt.wantServices) {
+				t.Errorf("newRequiredExtension() services = %v, want %v", services, tt.wantServices)
+			}
+		})
+	}
+}
+
+func TestCheckRequiredExtensions(t *testing.T) {
+	cert := &ssh.Certificate{
+		Permissions: ssh.Permissions{
+			Extensions: map[string]string{
+				"permit-sudo@ysshca": "",
+				"login@ysshca":       "alice",
+			},
+		},
+	}
+	tests := []struct {
+		name     string
+		required []RequiredExtension
+		wantErr  bool
+	}{
+		{
+			name: "no required extensions",
+		},
+		{
+			name: "all extensions present",
+			required: []RequiredExtension{
+				{Name: "permit-sudo@ysshca"},
+				{Name: "login@ysshca", Value: "alice", MatchValue: true},
+			},
+		},
+		{
+			name:     "missing extension",
+			required: []RequiredExtension{{Name: "permit-su@ysshca"}},
+			wantErr:  true,
+		},
+		{
+			name:     "value mismatch",
+			required: []RequiredExtension{{Name: "login@ysshca", Value: "bob", MatchValue: true}},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckRequiredExtensions(cert, tt.required); (err != nil) != tt.wantErr {
+				t.Errorf("CheckRequiredExtensions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
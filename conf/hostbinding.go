@@ -0,0 +1,61 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package conf
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// HostsOption is the name of the certificate extension or critical option that binds the certificate to hosts.
+// Its value is a comma-separated list of hostname globs, or host tag globs with prefix "tag:".
+const HostsOption = "hosts@ysshca"
+
+const hostTagPrefix = "tag:"
+
+// hostname returns the hostname of the local host. It is a variable for testing.
+var hostname = os.Hostname
+
+// CheckHostBinding returns nil if the certificate is not bound to hosts,
+// or if the local host matches any of the hosts that the certificate is bound to.
+// A hostname glob matches either the full hostname or its first label,
+// and a host tag glob matches any of the tags of the local host.
+func CheckHostBinding(cert *ssh.Certificate, hostTags []string) error {
+	value, ok := cert.CriticalOptions[HostsOption]
+	if !ok {
+		value, ok = cert.Extensions[HostsOption]
+	}
+	if !ok {
+		return nil
+	}
+
+	host, err := hostname()
+	if err != nil {
+		return fmt.Errorf("failed to get hostname: %v", err)
+	}
+	names := []string{strings.ToLower(host)}
+	if short, _, found := strings.Cut(names[0], "."); found {
+		names = append(names, short)
+	}
+
+	for _, pattern := range strings.Split(value, ",") {
+		pattern = strings.TrimSpace(pattern)
+		candidates := names
+		if strings.HasPrefix(pattern, hostTagPrefix) {
+			pattern = strings.TrimPrefix(pattern, hostTagPrefix)
+			candidates = hostTags
+		} else {
+			pattern = strings.ToLower(pattern)
+		}
+		for _, candidate := range candidates {
+			if pattern != "" && matchWildcard(candidate, pattern) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("certificate is bound to hosts (%s), which don't match host %s", value, host)
+}
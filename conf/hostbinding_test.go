@@ -0,0 +1,77 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package conf
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestCheckHostBinding(t *testing.T) {
+	defer func(f func() (string, error)) { hostname = f }(hostname)
+	hostname = func() (string, error) { return "Web-01.prod.example.com", nil }
+	tests := []struct {
+		name       string
+		extensions map[string]string
+		critical   map[string]string
+		hostTags   []string
+		wantErr    bool
+	}{
+		{
+			name: "not bound to hosts",
+		},
+		{
+			name:       "full hostname",
+			extensions: map[string]string{HostsOption: "db-01.prod.example.com,web-01.prod.example.com"},
+		},
+		{
+			name:       "short hostname glob",
+			extensions: map[string]string{HostsOption: "web-*"},
+		},
+		{
+			name:     "critical option",
+			critical: map[string]string{HostsOption: "*.prod.example.com"},
+		},
+		{
+			name:       "host tag",
+			extensions: map[string]string{HostsOption: "db-*, tag:prod-*"},
+			hostTags:   []string{"web", "prod-us"},
+		},
+		{
+			name:       "host tag mismatch",
+			extensions: map[string]string{HostsOption: "tag:prod-*"},
+			hostTags:   []string{"staging"},
+			wantErr:    true,
+		},
+		{
+			name:       "tag is not matched against hostname",
+			extensions: map[string]string{HostsOption: "tag:web-*"},
+			wantErr:    true,
+		},
+		{
+			name:       "hostname mismatch",
+			extensions: map[string]string{HostsOption: "*.staging.example.com"},
+			wantErr:    true,
+		},
+		{
+			name:       "empty list",
+			extensions: map[string]string{HostsOption: ""},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert := &ssh.Certificate{
+				Permissions: ssh.Permissions{
+					CriticalOptions: tt.critical,
+					Extensions:      tt.extensions,
+				},
+			}
+			if err := CheckHostBinding(cert, tt.hostTags); (err != nil) != tt.wantErr {
+				t.Errorf("CheckHostBinding() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
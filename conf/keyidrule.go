@@ -0,0 +1,192 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package conf
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/theparanoids/ysshra/keyid"
+)
+
+// keyIDRuleOps lists the operators of KeyIDRule.
+// Longer operators are listed first so that "!=" is not parsed as "!" followed by "=".
+var keyIDRuleOps = []string{"!=", "<=", ">=", "!~", "=", "<", ">", "~"}
+
+// keyIDFieldKinds maps the JSON names of the supported fields in Key ID to their kinds,
+// the same names as the selectors of AllowedTimes and Prompt.
+var keyIDFieldKinds = map[string]string{
+	"isHWKey":       "bool",
+	"isHeadless":    "bool",
+	"isFirefighter": "bool",
+	"usage":         "int",
+	"touchPolicy":   "int",
+	"ver":           "int",
+	"reqHost":       "string",
+}
+
+// KeyIDRule is a condition on a field in Key ID of SSH certs.
+// Please refer to the type `KeyID` in SSHRA repo for the fields.
+type KeyIDRule struct {
+	// Deny specifies whether the certificates matching the rule are rejected.
+	// Otherwise, the certificates not matching the rule are rejected.
+	Deny bool
+	// Field is the JSON name of the field in Key ID, such as "touchPolicy".
+	Field string
+	// Op is the comparison operator: "=", "!=", "<", "<=", ">", ">=", "~" (regex match) or "!~" (regex mismatch).
+	Op string
+	// Value is the value that the field is compared with.
+	Value string
+	// RE is the compiled Value for operators "~" and "!~".
+	RE *regexp.Regexp
+}
+
+// newKeyIDRule parses the Require or Deny directive in format `field<op>value [service[,service...]]`,
+// and returns the rule with the list of PAM services that it applies to.
+func newKeyIDRule(ruleStr string, deny bool) (KeyIDRule, []string, error) {
+	fields := strings.Fields(ruleStr)
+	if len(fields) == 0 || len(fields) > 2 {
+		return KeyIDRule{}, nil, fmt.Errorf("expected a condition and an optional list of services, got %d fields", len(fields))
+	}
+
+	cond := fields[0]
+	end := strings.IndexAny(cond, "!=<>~")
+	if end <= 0 {
+		return KeyIDRule{}, nil, fmt.Errorf("missing operator in %q", cond)
+	}
+	rule := KeyIDRule{Deny: deny, Field: cond[:end]}
+	for _, op := range keyIDRuleOps {
+		if strings.HasPrefix(cond[end:], op) {
+			rule.Op = op
+			rule.Value = cond[end+len(op):]
+			break
+		}
+	}
+	if rule.Op == "" {
+		return KeyIDRule{}, nil, fmt.Errorf("invalid operator in %q", cond)
+	}
+
+	kind, ok := keyIDFieldKinds[rule.Field]
+	if !ok {
+		return KeyIDRule{}, nil, fmt.Errorf("unsupported Key ID field %q", rule.Field)
+	}
+	switch rule.Op {
+	case "~", "!~":
+		re, err := regexp.Compile(rule.Value)
+		if err != nil {
+			return KeyIDRule{}, nil, err
+		}
+		rule.RE = re
+	case "<", "<=", ">", ">=":
+		if kind != "int" {
+			return KeyIDRule{}, nil, fmt.Errorf("operator %s is not supported by field %s", rule.Op, rule.Field)
+		}
+		fallthrough
+	default:
+		if err := validateKeyIDValue(kind, rule.Value); err != nil {
+			return KeyIDRule{}, nil, fmt.Errorf("invalid value for field %s: %v", rule.Field, err)
+		}
+	}
+
+	var services []string
+	if len(fields) == 2 {
+		services = strings.Split(fields[1], ",")
+	}
+	return rule, services, nil
+}
+
+func validateKeyIDValue(kind, value string) error {
+	var err error
+	switch kind {
+	case "bool":
+		_, err = parseBool(value)
+	case "int":
+		_, err = strconv.Atoi(value)
+	}
+	return err
+}
+
+// keyIDField returns the value of the field in Key ID in string.
+func keyIDField(kid *keyid.KeyID, field string) string {
+	switch field {
+	case "isHWKey":
+		return strconv.FormatBool(kid.IsHWKey)
+	case "isHeadless":
+		return strconv.FormatBool(kid.IsHeadless)
+	case "isFirefighter":
+		return strconv.FormatBool(kid.IsFirefighter)
+	case "usage":
+		return strconv.Itoa(int(kid.Usage))
+	case "touchPolicy":
+		return strconv.Itoa(int(kid.TouchPolicy))
+	case "ver":
+		return strconv.Itoa(int(kid.Version))
+	case "reqHost":
+		return kid.ReqHost
+	}
+	return ""
+}
+
+// Match returns true if the field in Key ID satisfies the condition of the rule.
+func (r KeyIDRule) Match(kid *keyid.KeyID) bool {
+	actual := keyIDField(kid, r.Field)
+	switch r.Op {
+	case "~":
+		return r.RE.MatchString(actual)
+	case "!~":
+		return !r.RE.MatchString(actual)
+	}
+
+	var cmp int
+	switch keyIDFieldKinds[r.Field] {
+	case "bool":
+		want, _ := parseBool(r.Value)
+		if strconv.FormatBool(want) != actual {
+			cmp = 1
+		}
+	case "int":
+		a, _ := strconv.Atoi(actual)
+		b, _ := strconv.Atoi(r.Value)
+		cmp = a - b
+	default:
+		cmp = strings.Compare(actual, r.Value)
+	}
+
+	switch r.Op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+// String returns the rule in the same format as the Require or Deny directive.
+func (r KeyIDRule) String() string {
+	directive := "Require"
+	if r.Deny {
+		directive = "Deny"
+	}
+	return fmt.Sprintf("%s %s%s%s", directive, r.Field, r.Op, r.Value)
+}
+
+// CheckKeyIDRules returns nil if the Key ID matches all the Require rules and none of the Deny rules.
+func CheckKeyIDRules(kid *keyid.KeyID, rules []KeyIDRule) error {
+	for _, rule := range rules {
+		if rule.Match(kid) == rule.Deny {
+			return fmt.Errorf("rejected by rule (%s)", rule)
+		}
+	}
+	return nil
+}
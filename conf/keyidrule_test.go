@@ -0,0 +1,77 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package conf
+
+import (
+	"testing"
+
+	"github.com/theparanoids/ysshra/keyid"
+)
+
+func Test_newKeyIDRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		ruleStr string
+		wantErr bool
+	}{
+		{name: "bool field", ruleStr: "isHWKey=true"},
+		{name: "int field with ordering operator", ruleStr: "touchPolicy>=2 sudo"},
+		{name: "regex", ruleStr: "reqHost~^jump-[0-9]+$"},
+		{name: "missing operator", ruleStr: "isHWKey", wantErr: true},
+		{name: "unsupported field", ruleStr: "transID=abc", wantErr: true},
+		{name: "Go field name", ruleStr: "IsHWKey=true", wantErr: true},
+		{name: "ordering operator on bool field", ruleStr: "isHWKey>true", wantErr: true},
+		{name: "invalid int value", ruleStr: "ver=one", wantErr: true},
+		{name: "invalid bool value", ruleStr: "isHeadless=maybe", wantErr: true},
+		{name: "invalid regex", ruleStr: "reqHost~(", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := newKeyIDRule(tt.ruleStr, false); (err != nil) != tt.wantErr {
+				t.Errorf("newKeyIDRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckKeyIDRules(t *testing.T) {
+	kid := &keyid.KeyID{
+		ReqHost:     "jump-01.example.com",
+		IsHWKey:     true,
+		IsHeadless:  false,
+		Usage:       keyid.AllUsage,
+		TouchPolicy: keyid.CachedTouch,
+		Version:     keyid.DefaultVersion,
+	}
+	tests := []struct {
+		name    string
+		rules   []string
+		deny    bool
+		wantErr bool
+	}{
+		{name: "require bool", rules: []string{"isHWKey=yes"}},
+		{name: "require bool fails", rules: []string{"isHeadless=true"}, wantErr: true},
+		{name: "require int comparison", rules: []string{"touchPolicy>=2", "ver<=1", "usage!=1"}},
+		{name: "require int comparison fails", rules: []string{"touchPolicy>2"}, wantErr: true},
+		{name: "require regex", rules: []string{"reqHost~^jump-"}},
+		{name: "require regex mismatch fails", rules: []string{"reqHost!~example\\.com$"}, wantErr: true},
+		{name: "deny matches", rules: []string{"isHWKey=true"}, deny: true, wantErr: true},
+		{name: "deny doesn't match", rules: []string{"reqHost=bastion"}, deny: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rules []KeyIDRule
+			for _, r := range tt.rules {
+				rule, _, err := newKeyIDRule(r, tt.deny)
+				if err != nil {
+					t.Fatal(err)
+				}
+				rules = append(rules, rule)
+			}
+			if err := CheckKeyIDRules(kid, rules); (err != nil) != tt.wantErr {
+				t.Errorf("CheckKeyIDRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -6,7 +6,10 @@ package conf
 import (
 	"os"
 	"path"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/theparanoids/pam-ysshca/decoder"
 	"github.com/theparanoids/pam-ysshca/filter"
@@ -17,13 +20,17 @@ import (
 type Parser struct {
 	userName string
 	userHome string
+	// service is the PAM service name, such as "sudo".
+	// Directives scoped to other services are ignored.
+	service string
 }
 
 // NewParser creates a Parser.
-func NewParser(userName, userHome string) *Parser {
+func NewParser(userName, userHome, service string) *Parser {
 	return &Parser{
 		userName: userName,
 		userHome: userHome,
+		service:  service,
 	}
 }
 
@@ -79,6 +86,11 @@ func (p *Parser) parse(data []byte) Config {
 		}
 	}
 
+	authorizedKeysCommand, err := config.Get("AuthorizedKeysCommand")
+	if authorizedKeysCommand != "" && err == nil {
+		result.AuthorizedKeysCommand = p.parseCommand(authorizedKeysCommand)
+	}
+
 	allow, err = config.Get("AllowCertificate")
 	if allow != "" && err == nil {
 		result.AllowCertificate, _ = parseBool(allow)
@@ -86,6 +98,22 @@ func (p *Parser) parse(data []byte) Config {
 
 	result.SupportedCriticalOptions, _ = config.GetAll("SupportedCriticalOption")
 
+	for directive, d := range map[string]*time.Duration{
+		"MaxCertLifetime":        &result.MaxCertLifetime,
+		"MinRemainingValidity":   &result.MinRemainingValidity,
+		"ClockSkew":              &result.ClockSkew,
+		"CryptoAuthChallengeTTL": &result.CryptoAuthChallengeTTL,
+		"CryptoAuthTimeout":      &result.CryptoAuthTimeout,
+	} {
+		value, err := config.Get(directive)
+		if value == "" || err != nil {
+			continue
+		}
+		if *d, err = parseDuration(value); err != nil {
+			msg.Printlf(msg.WARN, "Config: %s %s corrupt, err: %v", directive, value, err)
+		}
+	}
+
 	trustedUserCAKeys, err := config.GetAll("TrustedUserCAKeys")
 	if len(trustedUserCAKeys) != 0 && err == nil {
 		for _, a := range trustedUserCAKeys {
@@ -95,6 +123,18 @@ func (p *Parser) parse(data []byte) Config {
 
 	result.authorizedPrincipalPrefix, _ = config.GetAll("authorizedPrincipalPrefix")
 
+	authorizedGroupPrincipals, err := config.GetAll("AuthorizedGroupPrincipal")
+	if len(authorizedGroupPrincipals) != 0 && err == nil {
+		for _, template := range authorizedGroupPrincipals {
+			// A template without %g would authorize the same principal for every group of the user.
+			if !strings.Contains(template, "%g") {
+				msg.Printlf(msg.WARN, "Config: AuthorizedGroupPrincipal %s corrupt, err: missing %%g", template)
+				continue
+			}
+			result.authorizedGroupPrincipals = append(result.authorizedGroupPrincipals, template)
+		}
+	}
+
 	authorizedPrincipalsFiles, err := config.GetAll("AuthorizedPrincipalsFile")
 	if len(authorizedPrincipalsFiles) != 0 && err == nil {
 		for _, a := range authorizedPrincipalsFiles {
@@ -102,6 +142,189 @@ func (p *Parser) parse(data []byte) Config {
 		}
 	}
 
+	requiredExtensions, err := config.GetAll("RequiredExtension")
+	if len(requiredExtensions) != 0 && err == nil {
+		for _, e := range requiredExtensions {
+			requiredExtension, services, err := newRequiredExtension(strings.ReplaceAll(e, "%u", p.userName))
+			if err != nil {
+				msg.Printlf(msg.WARN, "Config: %s corrupt, err: %v", e, err)
+				continue
+			}
+			if !p.inServices(services) {
+				continue
+			}
+			result.RequiredExtensions = append(result.RequiredExtensions, requiredExtension)
+		}
+	}
+
+	for _, directive := range []string{"Require", "Deny"} {
+		rules, err := config.GetAll(directive)
+		if len(rules) == 0 || err != nil {
+			continue
+		}
+		for _, r := range rules {
+			rule, services, err := newKeyIDRule(r, directive == "Deny")
+			if err != nil {
+				msg.Printlf(msg.WARN, "Config: %s %s corrupt, err: %v", directive, r, err)
+				continue
+			}
+			if !p.inServices(services) {
+				continue
+			}
+			result.KeyIDRules = append(result.KeyIDRules, rule)
+		}
+	}
+
+	commandPolicies, err := config.GetAll("CommandPolicy")
+	if len(commandPolicies) != 0 && err == nil {
+		for _, c := range commandPolicies {
+			policy, err := newCommandPolicy(c)
+			if err != nil {
+				msg.Printlf(msg.WARN, "Config: %s corrupt, err: %v", c, err)
+				continue
+			}
+			result.CommandPolicies = append(result.CommandPolicies, policy)
+		}
+	}
+
+	allowFirefighters, err := config.GetAll("AllowFirefighter")
+	if len(allowFirefighters) != 0 && err == nil {
+		for _, a := range allowFirefighters {
+			fields := strings.Fields(a)
+			if len(fields) == 0 || len(fields) > 2 {
+				msg.Printlf(msg.WARN, "Config: AllowFirefighter %s corrupt", a)
+				continue
+			}
+			if len(fields) == 2 && !p.inServices(strings.Split(fields[1], ",")) {
+				continue
+			}
+			allow, err := parseBool(fields[0])
+			if err != nil {
+				msg.Printlf(msg.WARN, "Config: AllowFirefighter %s corrupt, err: %v", a, err)
+				continue
+			}
+			result.AllowFirefighter = allow
+		}
+	}
+
+	result.FirefighterNotify, _ = config.GetAll("FirefighterNotify")
+
+	notifyRequired, err := config.Get("FirefighterNotifyRequired")
+	if notifyRequired != "" && err == nil {
+		result.FirefighterNotifyRequired, _ = parseBool(notifyRequired)
+	}
+
+	allowedTimes, err := config.GetAll("AllowedTimes")
+	if len(allowedTimes) != 0 && err == nil {
+		for _, t := range allowedTimes {
+			allowedTime, services, err := newAllowedTime(t)
+			if err != nil {
+				msg.Printlf(msg.WARN, "Config: %s corrupt, err: %v", t, err)
+				continue
+			}
+			if !p.inServices(services) {
+				continue
+			}
+			result.AllowedTimes = append(result.AllowedTimes, allowedTime)
+		}
+	}
+
+	hostTags, err := config.GetAll("HostTags")
+	if len(hostTags) != 0 && err == nil {
+		for _, tags := range hostTags {
+			result.HostTags = append(result.HostTags, strings.FieldsFunc(tags, func(r rune) bool {
+				return r == ',' || unicode.IsSpace(r)
+			})...)
+		}
+	}
+
+	principalMaps, err := config.GetAll("PrincipalMap")
+	if len(principalMaps) != 0 && err == nil {
+		for _, m := range principalMaps {
+			principalMap, err := newPrincipalMap(m)
+			if err != nil {
+				msg.Printlf(msg.WARN, "Config: %s corrupt, err: %v", m, err)
+				continue
+			}
+			result.PrincipalMaps = append(result.PrincipalMaps, principalMap)
+		}
+	}
+
+	fallbackOn, err := config.Get("FallbackOn")
+	if fallbackOn != "" && err == nil {
+		if conditions, err := parseFallbackOn(fallbackOn); err != nil {
+			msg.Printlf(msg.WARN, "Config: FallbackOn %s corrupt, err: %v", fallbackOn, err)
+		} else {
+			result.FallbackOn = conditions
+		}
+	}
+
+	qrCode, err := config.Get("CryptoAuthQRCode")
+	if qrCode != "" && err == nil {
+		result.CryptoAuthQRCode, _ = parseBool(qrCode)
+	}
+
+	clientCommand, err := config.Get("CryptoAuthClientCommand")
+	if clientCommand != "" && err == nil {
+		if err := checkTokens(clientCommand, CryptoAuthClientCommandTokens); err != nil {
+			msg.Printlf(msg.WARN, "Config: CryptoAuthClientCommand %s corrupt, err: %v", clientCommand, err)
+		} else {
+			result.CryptoAuthClientCommand = clientCommand
+		}
+	}
+	for directive, str := range map[string]*string{
+		"CryptoAuthClientPrompt":    &result.CryptoAuthClientPrompt,
+		"CryptoAuthChallengePrompt": &result.CryptoAuthChallengePrompt,
+		"CryptoAuthResponsePrompt":  &result.CryptoAuthResponsePrompt,
+		"CryptoAuthHelpURL":         &result.CryptoAuthHelpURL,
+	} {
+		if value, err := config.Get(directive); value != "" && err == nil {
+			*str = value
+		}
+	}
+
+	receiptDir, err := config.Get("ReceiptDir")
+	if receiptDir != "" && err == nil {
+		if !path.IsAbs(receiptDir) {
+			msg.Printlf(msg.WARN, "Config: ReceiptDir %s corrupt, err: not an absolute path", receiptDir)
+		} else {
+			result.ReceiptDir = path.Clean(receiptDir)
+		}
+	}
+
+	auditSinks, err := config.GetAll("AuditSink")
+	if len(auditSinks) != 0 && err == nil {
+		var sinks []AuditSink
+		for _, a := range auditSinks {
+			sink, err := newAuditSink(a)
+			if err != nil {
+				msg.Printlf(msg.WARN, "Config: AuditSink %s corrupt, err: %v", a, err)
+				continue
+			}
+			sinks = append(sinks, sink)
+		}
+		// Keep the default sink if all the sinks are corrupt, so that the audit events are not lost.
+		if len(sinks) != 0 {
+			result.AuditSinks = sinks
+		}
+	}
+
+	for directive, n := range map[string]*int{
+		"CryptoAuthInputRetries":     &result.CryptoAuthInputRetries,
+		"CryptoAuthSignatureRetries": &result.CryptoAuthSignatureRetries,
+	} {
+		value, err := config.Get(directive)
+		if value == "" || err != nil {
+			continue
+		}
+		retries, err := strconv.Atoi(value)
+		if err != nil || retries < 0 {
+			msg.Printlf(msg.WARN, "Config: %s %s corrupt, expected a non-negative integer", directive, value)
+			continue
+		}
+		*n = retries
+	}
+
 	prompts, err := config.GetAll("Prompt")
 	if len(prompts) != 0 && err == nil {
 		for _, p := range prompts {
@@ -131,11 +354,36 @@ func (p *Parser) populate(c Config) Config {
 
 func (p *Parser) validate(c Config) Config {
 	c.StaticKeys = validateFiles(c.StaticKeys, -1, 0000, 0022)
+	c.AuthorizedKeysCommand = validateCommand(c.AuthorizedKeysCommand)
+	c.FirefighterNotify = validateNotifiers(c.FirefighterNotify)
 	c.CAKeys = validateFiles(c.CAKeys, 0, 0000, 0022)
 	c.authorizedPrincipalFiles = validateFiles(c.authorizedPrincipalFiles, 0, 0000, 0022)
 	return c
 }
 
+// inServices returns true if the list of PAM services is empty or contains the current service.
+func (p *Parser) inServices(services []string) bool {
+	if len(services) == 0 {
+		return true
+	}
+	for _, service := range services {
+		if service == p.service {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCommand splits the command line into the program and its arguments,
+// and replaces %u in the arguments with the username.
+func (p *Parser) parseCommand(cmdline string) []string {
+	fields := strings.Fields(cmdline)
+	for i := 1; i < len(fields); i++ {
+		fields[i] = strings.ReplaceAll(fields[i], "%u", p.userName)
+	}
+	return fields
+}
+
 func (p *Parser) extendFilePath(path_ string) string {
 	if strings.HasPrefix(path_, filter.EmbeddedPrefix) {
 		return path_
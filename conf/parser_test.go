@@ -7,6 +7,7 @@ import (
 	"reflect"
 	"regexp"
 	"testing"
+	"time"
 )
 
 const validConfig = `
@@ -18,12 +19,52 @@ AllowStaticKeys yes
 AuthorizedKeysFile /etc/ssh/sample1.pub
 AuthorizedKeysFile /etc/ssh/sample2.pub
 AuthorizedKeysFile /etc/ssh/%u.pub
+AuthorizedKeysCommand /usr/bin/lookup-keys --user %u
 AllowCertificate yes
 SupportedCriticalOption critical-option 
+MaxCertLifetime 8h
+MinRemainingValidity 5m
+ClockSkew -30s
 TrustedUserCAKeys /etc/ssh/sshuca
 AuthorizedPrincipalsFile /etc/testAPfile
 AuthorizedPrincipalPrefix screwdriver:
+AuthorizedGroupPrincipal group:%g
+AuthorizedGroupPrincipal sre-team
+RequiredExtension permit-sudo@ysshca sudo,sudo-i
+RequiredExtension login@ysshca=%u
+RequiredExtension permit-su@ysshca su
+Require touchPolicy>=2 sudo
+Deny isHeadless=yes
+Deny reqHost~^jump- su
+Require IsHWKey=true
+CommandPolicy hwkey,no-static-keys rm   -rf *
+AllowFirefighter no
+AllowFirefighter yes sudo,sudo-i
+AllowFirefighter no su
+AllowFirefighter maybe
+FirefighterNotify https://alerts.example.com/firefighter
+FirefighterNotifyRequired yes
+HostTags prod, web
+AllowedTimes isHeadless=true Sat 00:00-06:00 tz=UTC sudo
+AllowedTimes principal=oncall-* Mon-Fri 09:00-17:00 su
+AllowedTimes * Mon-Funday *
+HostTags us-west
+PrincipalMap (.*)@CORP\.EXAMPLE $1
+PrincipalMap invalid-map
 Prompt touchPolicy=(2|3) Touch YubiKey:
+FallbackOn no-agent,challenge-failed
+CryptoAuthQRCode yes
+CryptoAuthInputRetries 3
+CryptoAuthSignatureRetries -1
+CryptoAuthChallengeTTL 2m
+CryptoAuthClientCommand my-client --user %u --host %h
+CryptoAuthResponsePrompt Paste the response from my-client:
+CryptoAuthHelpURL https://wiki.example.com/cryptoauth
+ReceiptDir /var/lib/pam_sshca/receipts/
+AuditSink json /var/log/pam_sshca/audit.jsonl
+AuditSink journald
+AuditSink rfc5424 udp://syslog.example.com:514
+AuditSink rfc5424 syslog.example.com
 `
 
 func TestParser_extendFilePath(t *testing.T) {
@@ -56,7 +97,7 @@ func TestParser_extendFilePath(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			p := NewParser(tt.userName, tt.userHome)
+			p := NewParser(tt.userName, tt.userHome, "sudo")
 			if got := p.extendFilePath(tt.inputPath); got != tt.want {
 				t.Errorf("extendFilePath() = %v, want %v", got, tt.want)
 			}
@@ -89,19 +130,70 @@ func TestParser_ParseConfigFile(t *testing.T) {
 					"/etc/ssh/sample2.pub",
 					"/etc/ssh/example_user.pub",
 				},
+				AuthorizedKeysCommand: []string{
+					"/usr/bin/lookup-keys",
+					"--user",
+					"example_user",
+				},
 				AllowCertificate: true,
 				SupportedCriticalOptions: []string{
 					"critical-option",
 				},
+				MaxCertLifetime:      8 * time.Hour,
+				MinRemainingValidity: 5 * time.Minute,
+				RequiredExtensions: []RequiredExtension{
+					{Name: "permit-sudo@ysshca"},
+					{Name: "login@ysshca", Value: "example_user", MatchValue: true},
+				},
+				KeyIDRules: []KeyIDRule{
+					{Field: "touchPolicy", Op: ">=", Value: "2"},
+					{Deny: true, Field: "isHeadless", Op: "=", Value: "yes"},
+				},
+				CommandPolicies: []CommandPolicy{
+					{
+						Glob:         "rm -rf *",
+						KeyIDRules:   []KeyIDRule{{Field: "isHWKey", Op: "=", Value: "true"}},
+						NoStaticKeys: true,
+					},
+				},
+				AllowFirefighter: true,
+				FirefighterNotify: []string{
+					"https://alerts.example.com/firefighter",
+				},
+				FirefighterNotifyRequired: true,
+				AllowedTimes: []AllowedTime{
+					{
+						KeyIDProperty: "isHeadless",
+						RE:            regexp.MustCompile("true"),
+						Weekdays:      [7]bool{false, false, false, false, false, false, true},
+						Start:         0,
+						End:           360,
+						Location:      time.UTC,
+					},
+				},
+				HostTags: []string{
+					"prod",
+					"web",
+					"us-west",
+				},
 				CAKeys: []string{
 					"/etc/ssh/sshuca",
 				},
 				authorizedPrincipalPrefix: []string{
 					"screwdriver:",
 				},
+				authorizedGroupPrincipals: []string{
+					"group:%g",
+				},
 				authorizedPrincipalFiles: []string{
 					"/etc/testAPfile",
 				},
+				PrincipalMaps: []PrincipalMap{
+					{
+						RE:          regexp.MustCompile(`^(?:(.*)@CORP\.EXAMPLE)$`),
+						Replacement: "$1",
+					},
+				},
 				Prompters: []Prompter{
 					{
 						KeyIDProperty: "touchPolicy",
@@ -109,6 +201,21 @@ func TestParser_ParseConfigFile(t *testing.T) {
 						Message:       "Touch YubiKey:",
 					},
 				},
+				FallbackOn:                 []string{FallbackNoAgent, FallbackChallengeFailed},
+				CryptoAuthQRCode:           true,
+				CryptoAuthInputRetries:     3,
+				CryptoAuthSignatureRetries: 1,
+				CryptoAuthChallengeTTL:     2 * time.Minute,
+				CryptoAuthTimeout:          10 * time.Minute,
+				CryptoAuthClientCommand:    "my-client --user %u --host %h",
+				CryptoAuthResponsePrompt:   "Paste the response from my-client:",
+				CryptoAuthHelpURL:          "https://wiki.example.com/cryptoauth",
+				ReceiptDir:                 "/var/lib/pam_sshca/receipts",
+				AuditSinks: []AuditSink{
+					{Type: "json", Target: "/var/log/pam_sshca/audit.jsonl"},
+					{Type: "journald"},
+					{Type: "rfc5424", Target: "udp://syslog.example.com:514"},
+				},
 			},
 		},
 	}
@@ -117,6 +224,7 @@ func TestParser_ParseConfigFile(t *testing.T) {
 			p := &Parser{
 				userName: tt.userName,
 				userHome: tt.userHome,
+				service:  "sudo",
 			}
 			if got := p.parse(tt.config); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("ParseConfigFile() = %v, want %v", got, tt.want)
@@ -0,0 +1,234 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package conf
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// PrincipalOptions are the sshd options of an entry in the authorized principals file.
+// Please refer to AUTHORIZED_KEYS FILE FORMAT in sshd(8) for the syntax.
+// A zero PrincipalOptions permits the principal unconditionally.
+type PrincipalOptions struct {
+	// From is the pattern-list specified by from="...".
+	// The remote address of the user must match the pattern-list.
+	From []string
+	// ExpiryTime is the time specified by expiry-time="...".
+	// The entry is not accepted after the time.
+	ExpiryTime time.Time
+	// Command is the command specified by command="...".
+	// The entry is accepted only for the given command.
+	Command string
+}
+
+// ignoredPrincipalOptions are the sshd options that don't apply to PAM-SSHCA.
+var ignoredPrincipalOptions = map[string]bool{
+	"agent-forwarding":    true,
+	"no-agent-forwarding": true,
+	"no-port-forwarding":  true,
+	"no-pty":              true,
+	"no-touch-required":   true,
+	"no-user-rc":          true,
+	"no-x11-forwarding":   true,
+	"port-forwarding":     true,
+	"pty":                 true,
+	"restrict":            true,
+	"user-rc":             true,
+	"verify-required":     true,
+	"x11-forwarding":      true,
+	"environment":         true,
+	"permitlisten":        true,
+	"permitopen":          true,
+	"tunnel":              true,
+}
+
+// parsePrincipalsLine parses a line in the authorized principals file.
+// Same as sshd, the principal is the last field of the line, and the fields before it are the options.
+// It returns an empty principal if the line is a comment or an empty line.
+func parsePrincipalsLine(line string) (principal string, opts PrincipalOptions, err error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", opts, nil
+	}
+	sep := strings.LastIndexAny(line, " \t")
+	if sep < 0 {
+		return line, opts, nil
+	}
+	principal = line[sep+1:]
+	opts, err = parsePrincipalOptions(strings.TrimSpace(line[:sep]))
+	if err != nil {
+		return "", opts, err
+	}
+	return principal, opts, nil
+}
+
+// parsePrincipalOptions parses the comma-separated options, e.g. `from="10.0.0.0/8",command="ls -l"`.
+func parsePrincipalOptions(str string) (PrincipalOptions, error) {
+	var opts PrincipalOptions
+	for str != "" {
+		var (
+			name, value string
+			hasValue    bool
+			err         error
+		)
+		end := strings.IndexAny(str, ",=")
+		if end < 0 {
+			end = len(str)
+		}
+		name = strings.ToLower(str[:end])
+		str = str[end:]
+		if strings.HasPrefix(str, "=") {
+			hasValue = true
+			value, str, err = readQuoted(str[1:])
+			if err != nil {
+				return opts, fmt.Errorf("option %s: %v", name, err)
+			}
+		}
+		if str != "" {
+			if !strings.HasPrefix(str, ",") {
+				return opts, fmt.Errorf("unexpected %q after option %s", str, name)
+			}
+			str = str[1:]
+		}
+
+		switch {
+		case name == "from" && hasValue:
+			opts.From = strings.Split(value, ",")
+		case name == "expiry-time" && hasValue:
+			opts.ExpiryTime, err = parseExpiryTime(value)
+			if err != nil {
+				return opts, err
+			}
+		case name == "command" && hasValue:
+			opts.Command = value
+		case ignoredPrincipalOptions[name]:
+		default:
+			return opts, fmt.Errorf("unsupported option %q", name)
+		}
+	}
+	return opts, nil
+}
+
+// readQuoted reads a double-quoted value from the beginning of str, and returns the value and the rest of str.
+// A double quote can be included in the value by escaping it with a backslash.
+func readQuoted(str string) (value string, rest string, err error) {
+	if !strings.HasPrefix(str, `"`) {
+		return "", str, fmt.Errorf("missing start quote")
+	}
+	var b strings.Builder
+	for i := 1; i < len(str); i++ {
+		switch {
+		case str[i] == '\\' && i+1 < len(str) && str[i+1] == '"':
+			b.WriteByte('"')
+			i++
+		case str[i] == '"':
+			return b.String(), str[i+1:], nil
+		default:
+			b.WriteByte(str[i])
+		}
+	}
+	return "", "", fmt.Errorf("missing end quote")
+}
+
+// parseExpiryTime parses the time in format YYYYMMDD[Z] or YYYYMMDDHHMM[SS][Z].
+// The time is in the system time zone unless it ends with a Z character.
+func parseExpiryTime(str string) (time.Time, error) {
+	loc := time.Local
+	if strings.HasSuffix(str, "Z") || strings.HasSuffix(str, "z") {
+		loc = time.UTC
+		str = str[:len(str)-1]
+	}
+	var layout string
+	switch len(str) {
+	case 8:
+		layout = "20060102"
+	case 12:
+		layout = "200601021504"
+	case 14:
+		layout = "20060102150405"
+	default:
+		return time.Time{}, fmt.Errorf("invalid expiry-time %q", str)
+	}
+	t, err := time.ParseInLocation(layout, str, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid expiry-time %q: %v", str, err)
+	}
+	return t, nil
+}
+
+// Permit returns nil if the options permit the request from remoteAddr to run cmd at the given time.
+// cmd is the command line of the PAM application, its first argument (e.g. "sudo") is not compared with the command option.
+func (o PrincipalOptions) Permit(remoteAddr string, cmd string, now time.Time) error {
+	if !o.ExpiryTime.IsZero() && now.After(o.ExpiryTime) {
+		return fmt.Errorf("principal expired at %v", o.ExpiryTime)
+	}
+	if len(o.From) != 0 && !matchPatternList(remoteAddr, o.From) {
+		return fmt.Errorf("remote address %q is not permitted by from=%q", remoteAddr, strings.Join(o.From, ","))
+	}
+	if o.Command != "" {
+		args := strings.Fields(cmd)
+		if len(args) == 0 || strings.Join(args[1:], " ") != strings.Join(strings.Fields(o.Command), " ") {
+			return fmt.Errorf("command %q is not permitted by command=%q", cmd, o.Command)
+		}
+	}
+	return nil
+}
+
+// matchPatternList matches the address against a sshd pattern-list.
+// Each pattern is a CIDR, or a wildcard pattern with '*' and '?', and can be negated with a leading '!'.
+// The address matches if any pattern matches, and no negated pattern matches.
+func matchPatternList(addr string, patterns []string) bool {
+	if addr == "" {
+		return false
+	}
+	matched := false
+	for _, pattern := range patterns {
+		negated := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimPrefix(pattern, "!")
+		if !matchPattern(addr, pattern) {
+			continue
+		}
+		if negated {
+			return false
+		}
+		matched = true
+	}
+	return matched
+}
+
+func matchPattern(addr, pattern string) bool {
+	if _, ipnet, err := net.ParseCIDR(pattern); err == nil {
+		ip := net.ParseIP(addr)
+		return ip != nil && ipnet.Contains(ip)
+	}
+	return matchWildcard(strings.ToLower(addr), strings.ToLower(pattern))
+}
+
+// matchWildcard matches str against pattern, where '*' matches any sequence of characters and '?' matches any single character.
+func matchWildcard(str, pattern string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(str); i >= 0; i-- {
+				if matchWildcard(str[i:], pattern[1:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(str) == 0 {
+				return false
+			}
+		default:
+			if len(str) == 0 || str[0] != pattern[0] {
+				return false
+			}
+		}
+		str, pattern = str[1:], pattern[1:]
+	}
+	return len(str) == 0
+}
@@ -0,0 +1,148 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package conf
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_parsePrincipalsLine(t *testing.T) {
+	tests := []struct {
+		name          string
+		line          string
+		wantPrincipal string
+		wantOpts      PrincipalOptions
+		wantErr       bool
+	}{
+		{
+			name:          "principal only",
+			line:          "  alice ",
+			wantPrincipal: "alice",
+		},
+		{
+			name: "comment",
+			line: "# alice",
+		},
+		{
+			name:          "from option",
+			line:          `from="10.0.0.0/8,*.example.com" alice`,
+			wantPrincipal: "alice",
+			wantOpts:      PrincipalOptions{From: []string{"10.0.0.0/8", "*.example.com"}},
+		},
+		{
+			name:          "quoted value with spaces, commas and escaped quotes",
+			line:          `command="echo \"a, b\"",FROM="host?" alice`,
+			wantPrincipal: "alice",
+			wantOpts:      PrincipalOptions{Command: `echo "a, b"`, From: []string{"host?"}},
+		},
+		{
+			name:          "expiry time",
+			line:          `expiry-time="203001021530Z" alice`,
+			wantPrincipal: "alice",
+			wantOpts:      PrincipalOptions{ExpiryTime: time.Date(2030, 1, 2, 15, 30, 0, 0, time.UTC)},
+		},
+		{
+			name:          "ignored options",
+			line:          `restrict,no-pty,environment="A=B" alice`,
+			wantPrincipal: "alice",
+		},
+		{
+			name:    "invalid expiry time",
+			line:    `expiry-time="2030" alice`,
+			wantErr: true,
+		},
+		{
+			name:    "missing end quote",
+			line:    `from="10.0.0.0/8 alice`,
+			wantErr: true,
+		},
+		{
+			name:    "unsupported option",
+			line:    `cert-authority alice`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, opts, err := parsePrincipalsLine(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePrincipalsLine() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if principal != tt.wantPrincipal {
+				t.Errorf("parsePrincipalsLine() principal = %q, want %q", principal, tt.wantPrincipal)
+			}
+			if !reflect.DeepEqual(opts, tt.wantOpts) {
+				t.Errorf("parsePrincipalsLine() opts = %+v, want %+v", opts, tt.wantOpts)
+			}
+		})
+	}
+}
+
+func TestPrincipalOptions_Permit(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		opts       PrincipalOptions
+		remoteAddr string
+		cmd        string
+		wantErr    bool
+	}{
+		{
+			name: "no options",
+		},
+		{
+			name:       "from cidr",
+			opts:       PrincipalOptions{From: []string{"10.0.0.0/8", "!10.1.0.0/16"}},
+			remoteAddr: "10.2.3.4",
+		},
+		{
+			name:       "from negated cidr",
+			opts:       PrincipalOptions{From: []string{"10.0.0.0/8", "!10.1.0.0/16"}},
+			remoteAddr: "10.1.3.4",
+			wantErr:    true,
+		},
+		{
+			name:       "from wildcard",
+			opts:       PrincipalOptions{From: []string{"*.example.com"}},
+			remoteAddr: "Jump.Example.com",
+		},
+		{
+			name:    "from with unknown remote address",
+			opts:    PrincipalOptions{From: []string{"*"}},
+			wantErr: true,
+		},
+		{
+			name:    "expired",
+			opts:    PrincipalOptions{ExpiryTime: now.Add(-time.Second)},
+			wantErr: true,
+		},
+		{
+			name: "not expired",
+			opts: PrincipalOptions{ExpiryTime: now.Add(time.Second)},
+		},
+		{
+			name: "command matches",
+			opts: PrincipalOptions{Command: "systemctl  restart nginx"},
+			cmd:  "sudo systemctl restart nginx",
+		},
+		{
+			name:    "command mismatches",
+			opts:    PrincipalOptions{Command: "systemctl restart nginx"},
+			cmd:     "sudo systemctl stop nginx",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.Permit(tt.remoteAddr, tt.cmd, now); (err != nil) != tt.wantErr {
+				t.Errorf("Permit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
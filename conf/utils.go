@@ -5,6 +5,7 @@ package conf
 
 import (
 	"fmt"
+	"strings"
 )
 
 // ParseBool returns the boolean value represented by the string.
@@ -20,3 +21,44 @@ func parseBool(str string) (bool, error) {
 	}
 	return false, fmt.Errorf("parse %s error", str)
 }
+
+// parseFallbackOn parses the comma-separated conditions of FallbackOn directive.
+// "never" disables the fallback, and cannot be combined with other conditions.
+func parseFallbackOn(str string) ([]string, error) {
+	conditions := strings.Split(str, ",")
+	for _, condition := range conditions {
+		switch condition {
+		case FallbackNoAgent, FallbackNoValidIdentity, FallbackChallengeFailed:
+		case FallbackNever:
+			if len(conditions) != 1 {
+				return nil, fmt.Errorf("%s cannot be combined with other conditions", FallbackNever)
+			}
+			return []string{}, nil
+		default:
+			return nil, fmt.Errorf("unsupported condition %q", condition)
+		}
+	}
+	return conditions, nil
+}
+
+// checkTokens returns an error if the template has a token (% followed by a character) not in tokens.
+func checkTokens(template string, tokens []string) error {
+	for i := 0; i < len(template); i++ {
+		if template[i] != '%' {
+			continue
+		}
+		if i+1 == len(template) {
+			return fmt.Errorf("incomplete token at the end")
+		}
+		token := template[i : i+2]
+		valid := false
+		for _, t := range tokens {
+			valid = valid || t == token
+		}
+		if !valid {
+			return fmt.Errorf("unknown token %s", token)
+		}
+		i++
+	}
+	return nil
+}
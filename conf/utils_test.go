@@ -3,7 +3,10 @@
 
 package conf
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
 func Test_parseBool(t *testing.T) {
 	tests := []struct {
@@ -42,3 +45,83 @@ func Test_parseBool(t *testing.T) {
 		})
 	}
 }
+
+func Test_parseFallbackOn(t *testing.T) {
+	tests := []struct {
+		name    string
+		str     string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "single condition",
+			str:  "no-agent",
+			want: []string{FallbackNoAgent},
+		},
+		{
+			name: "multiple conditions",
+			str:  "no-agent,no-valid-identity,challenge-failed",
+			want: []string{FallbackNoAgent, FallbackNoValidIdentity, FallbackChallengeFailed},
+		},
+		{
+			name: "never",
+			str:  "never",
+			want: []string{},
+		},
+		{
+			name:    "never with other conditions",
+			str:     "never,no-agent",
+			wantErr: true,
+		},
+		{
+			name:    "unsupported condition",
+			str:     "always",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseFallbackOn(tt.str)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseFallbackOn() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseFallbackOn() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_checkTokens(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		wantErr  bool
+	}{
+		{
+			name:     "all tokens",
+			template: "my-client --user %u --host %h --service %s --nonce %n --discount 100%%",
+		},
+		{
+			name:     "no token",
+			template: "my-client",
+		},
+		{
+			name:     "unknown token",
+			template: "my-client --group %g",
+			wantErr:  true,
+		},
+		{
+			name:     "incomplete token",
+			template: "my-client %",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkTokens(tt.template, CryptoAuthClientCommandTokens); (err != nil) != tt.wantErr {
+				t.Errorf("checkTokens() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -29,6 +29,39 @@ func validateFiles(files []string, owner int, require os.FileMode, deny os.FileM
 	return result
 }
 
+// validateCommand validates the program of a command line.
+// The program must be an absolute path owned by root, and not writable by group or others.
+// It returns nil if the program doesn't pass the check.
+func validateCommand(cmdline []string) []string {
+	if len(cmdline) == 0 {
+		return nil
+	}
+	if !strings.HasPrefix(cmdline[0], "/") {
+		msg.Printlf(msg.WARN, "Command %s is not an absolute path", cmdline[0])
+		return nil
+	}
+	if len(validateFiles(cmdline[:1], 0, 0000, 0022)) == 0 {
+		return nil
+	}
+	return cmdline
+}
+
+// validateNotifiers validates the list of notifiers.
+// A notifier is either a webhook URL, or a program that passes validateCommand.
+func validateNotifiers(notifiers []string) []string {
+	var result []string
+	for _, notifier := range notifiers {
+		if strings.HasPrefix(notifier, "https://") || strings.HasPrefix(notifier, "http://") {
+			result = append(result, notifier)
+			continue
+		}
+		if validateCommand([]string{notifier}) != nil {
+			result = append(result, notifier)
+		}
+	}
+	return result
+}
+
 // validateFilePermission check whether the file have suitable ownership or permissions.
 // uid is the uid of suitable owner, -1 means anyone
 // require is the permission required, 0000 requires nothing
@@ -0,0 +1,72 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package conf
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// parseDuration parses a non-negative duration, such as "8h" or "30s".
+func parseDuration(str string) (time.Duration, error) {
+	d, err := time.ParseDuration(str)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("negative duration %s", str)
+	}
+	return d, nil
+}
+
+// CheckValidity returns nil if the validity period of the certificate satisfies the policy:
+// - the validity period is not longer than maxLifetime.
+// - the certificate stays valid for at least minRemaining after now.
+// A zero maxLifetime or minRemaining disables the corresponding check.
+// The timestamps of the certificate against now are checked by ssh.CertChecker with the clock from SkewedClock.
+func CheckValidity(cert *ssh.Certificate, now time.Time, maxLifetime, minRemaining time.Duration) error {
+	if maxLifetime > 0 {
+		if cert.ValidBefore == ssh.CertTimeInfinity {
+			return fmt.Errorf("certificate never expires, maximum lifetime is %s", maxLifetime)
+		}
+		if cert.ValidBefore <= cert.ValidAfter {
+			return fmt.Errorf("certificate has an empty validity period")
+		}
+		lifetime := time.Duration(cert.ValidBefore-cert.ValidAfter) * time.Second
+		if lifetime > maxLifetime {
+			return fmt.Errorf("certificate lifetime %s exceeds the maximum %s", lifetime, maxLifetime)
+		}
+	}
+	if minRemaining > 0 && cert.ValidBefore != ssh.CertTimeInfinity {
+		remaining := time.Unix(int64(cert.ValidBefore), 0).Sub(now)
+		if remaining < minRemaining {
+			return fmt.Errorf("certificate expires in %s, minimum remaining validity is %s", remaining.Truncate(time.Second), minRemaining)
+		}
+	}
+	return nil
+}
+
+// SkewedClock returns the clock for ssh.CertChecker to check the timestamps of the certificate,
+// which tolerates the difference of skew between the clocks of the local host and the CA.
+// A certificate that is not yet valid, or has expired, within skew at now is treated as valid.
+func SkewedClock(cert *ssh.Certificate, now time.Time, skew time.Duration) func() time.Time {
+	return func() time.Time {
+		if skew <= 0 {
+			return now
+		}
+		validAfter := time.Unix(int64(cert.ValidAfter), 0)
+		if now.Before(validAfter) && !now.Add(skew).Before(validAfter) {
+			return validAfter
+		}
+		if cert.ValidBefore != ssh.CertTimeInfinity {
+			validBefore := time.Unix(int64(cert.ValidBefore), 0)
+			if !now.Before(validBefore) && now.Add(-skew).Before(validBefore) {
+				return validBefore.Add(-time.Second)
+			}
+		}
+		return now
+	}
+}
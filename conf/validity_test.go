@@ -0,0 +1,142 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package conf
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestCheckValidity(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	validity := func(after, before time.Duration) *ssh.Certificate {
+		return &ssh.Certificate{
+			ValidAfter:  uint64(now.Add(after).Unix()),
+			ValidBefore: uint64(now.Add(before).Unix()),
+		}
+	}
+	tests := []struct {
+		name         string
+		cert         *ssh.Certificate
+		maxLifetime  time.Duration
+		minRemaining time.Duration
+		wantErr      bool
+	}{
+		{
+			name: "no policy",
+			cert: &ssh.Certificate{ValidBefore: ssh.CertTimeInfinity},
+		},
+		{
+			name:         "within policy",
+			cert:         validity(-time.Hour, 7*time.Hour),
+			maxLifetime:  8 * time.Hour,
+			minRemaining: 5 * time.Minute,
+		},
+		{
+			name:        "lifetime too long",
+			cert:        validity(-time.Hour, 7*time.Hour+time.Second),
+			maxLifetime: 8 * time.Hour,
+			wantErr:     true,
+		},
+		{
+			name:        "never expires",
+			cert:        &ssh.Certificate{ValidBefore: ssh.CertTimeInfinity},
+			maxLifetime: 8 * time.Hour,
+			wantErr:     true,
+		},
+		{
+			name:         "about to expire",
+			cert:         validity(-time.Hour, 4*time.Minute),
+			minRemaining: 5 * time.Minute,
+			wantErr:      true,
+		},
+		{
+			name:         "never expires has enough remaining validity",
+			cert:         &ssh.Certificate{ValidBefore: ssh.CertTimeInfinity},
+			minRemaining: 5 * time.Minute,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckValidity(tt.cert, now, tt.maxLifetime, tt.minRemaining); (err != nil) != tt.wantErr {
+				t.Errorf("CheckValidity() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSkewedClock(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := &ssh.Certificate{
+		Key:         sshPub,
+		CertType:    ssh.UserCert,
+		ValidAfter:  uint64(now.Add(time.Minute).Unix()),
+		ValidBefore: uint64(now.Add(time.Hour).Unix()),
+	}
+	if err := cert.SignCert(rand.Reader, signer); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		now     time.Time
+		skew    time.Duration
+		wantErr bool
+	}{
+		{
+			name:    "not yet valid without skew",
+			now:     now,
+			wantErr: true,
+		},
+		{
+			name: "not yet valid within skew",
+			now:  now,
+			skew: 2 * time.Minute,
+		},
+		{
+			name:    "not yet valid beyond skew",
+			now:     now,
+			skew:    30 * time.Second,
+			wantErr: true,
+		},
+		{
+			name: "expired within skew",
+			now:  now.Add(time.Hour + time.Minute),
+			skew: 2 * time.Minute,
+		},
+		{
+			name:    "expired beyond skew",
+			now:     now.Add(time.Hour + 3*time.Minute),
+			skew:    2 * time.Minute,
+			wantErr: true,
+		},
+		{
+			name: "valid",
+			now:  now.Add(30 * time.Minute),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := ssh.CertChecker{Clock: SkewedClock(cert, tt.now, tt.skew)}
+			if err := checker.CheckCert("", cert); (err != nil) != tt.wantErr {
+				t.Errorf("CheckCert() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -4,21 +4,47 @@
 package cryptoauth
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"log/syslog"
+	"strings"
+	"time"
 
+	"github.com/theparanoids/pam-ysshca/audit"
 	"github.com/theparanoids/pam-ysshca/conf"
 	"github.com/theparanoids/pam-ysshca/msg"
+	"github.com/theparanoids/pam-ysshca/qrcode"
+	"github.com/theparanoids/pam-ysshca/sshutils/armor"
 	"github.com/theparanoids/pam-ysshca/sshutils/challenge"
+	"github.com/theparanoids/pam-ysshca/sshutils/receipt"
 	"github.com/theparanoids/ysshra/sshutils/key"
 	"golang.org/x/crypto/ssh"
 )
 
 const (
 	clientCommandPrompt     = "No working ssh-agent connection found. If this is expected, please authenticate manually by running the following command in a terminal window on your client computer and pasting the resulting output here:"
-	clientCommand           = "cryptoauth-client %s"
+	clientCommand           = "cryptoauth-client -u %u -n %n"
+	helpURLPrompt           = "For help, please refer to %s"
 	challengePrompt         = "Please copy the following data and paste it in client's window to start authentication."
+	sshsigPrompt            = "Alternatively, sign the challenge with OpenSSH by running the following command on your client computer, and paste the resulting signature here:"
+	sshsigCommand           = "printf '%%s' '%s' | ssh-keygen -Y sign -n %s -f <private key or public key in ssh-agent>"
 	challengeResponsePrompt = "Paste signed response from client: "
+	retryInputPrompt        = "Malformed input (%v). Please paste it again (%d retries left): "
+	retryChallengePrompt    = "Challenge failed (%v). A new challenge is issued (%d retries left)."
+	extraKeyPrompt          = "Ignored another key pasted after the first one (%s). Only the first key is challenged; paste multiple keys in an armored block, such as the output of cryptoauth-client."
+	// certificateType is the armor type of the certificates.
+	certificateType = "PAM-SSHCA CERTIFICATE"
+	// authNonceSize is the size of the nonce of the authentication in bytes.
+	authNonceSize = 16
+)
+
+var (
+	// ErrMaxTries is wrapped by the errors of Authenticate if the user runs out of the retries,
+	// either for the malformed input or for the failed challenges.
+	ErrMaxTries = errors.New("maximum number of tries exceeded")
+	// errVerification is wrapped by the errors of the challenge responses that fail the verification.
+	errVerification = errors.New("failed to verify Challenge")
 )
 
 // checker is the interface to check whether an SSH certificate is valid or not.
@@ -27,105 +53,342 @@ type checker interface {
 	CheckCert(cert *ssh.Certificate, principal string) error
 }
 
+// validator is the interface of the certificate validation pipeline shared with the ssh-agent path,
+// such as pam.Validator.
+type validator interface {
+	checker
+	// CheckStaticKey returns nil if the public key is a valid static key for the user to authenticate.
+	CheckStaticKey(key ssh.PublicKey) error
+	// ChallengeContext returns the context of the authentication request that the challenges are bound to.
+	ChallengeContext(principal string) challenge.Context
+	// Prompt returns the message to print before challenging the certificate, or an empty string.
+	Prompt(cert *ssh.Certificate) string
+	// Prompter returns the Prompter that reads the input of users, which is shared with Approve.
+	Prompter() *msg.Prompter
+	// Approve completes the authentication by the certificate after its challenge succeeds.
+	Approve(cert *ssh.Certificate) error
+	// SaveReceipt saves the signed challenge of the grant as its receipt.
+	SaveReceipt(r *receipt.Receipt) error
+	// Audit records the audit event of the grant.
+	audit.Audit
+}
+
 // Authenticator is the struct to perform ASCII Crypto Challenge with users without accessing ssh-agent.
 type Authenticator struct {
-	*ssh.CertChecker
+	validator              validator
 	prompter               *msg.Prompter
 	clientArgs             string
+	clientCommand          string
+	clientPrompt           string
+	challengePrompt        string
+	responsePrompt         string
+	helpURL                string
+	qrCode                 bool
+	inputRetries           int
+	signatureRetries       int
+	challengeTTL           time.Duration
+	timeout                time.Duration
 	additionalCertCheckers []checker
-	userCAKeysFiles        []string
 }
 
 // NewAuthenticator returns a new Authenticator.
-func NewAuthenticator(config conf.Config, clientArgs string, certChecker *ssh.CertChecker, additionalCertCheckers ...checker) *Authenticator {
+// The client command and the messages are customized by the config, and clientArgs are appended to the client command.
+// The certificates are validated by validator, followed by the additional cert checkers.
+// The input of users is read by the Prompter of validator, which also reads the input required by Approve.
+func NewAuthenticator(config conf.Config, clientArgs string, validator validator, additionalCertCheckers ...checker) *Authenticator {
 	auth := &Authenticator{
-		CertChecker:            certChecker,
-		prompter:               msg.NewPrompter(),
+		validator:              validator,
+		prompter:               validator.Prompter(),
 		clientArgs:             clientArgs,
-		userCAKeysFiles:        config.CAKeys,
+		clientCommand:          valueOr(config.CryptoAuthClientCommand, clientCommand),
+		clientPrompt:           valueOr(config.CryptoAuthClientPrompt, clientCommandPrompt),
+		challengePrompt:        valueOr(config.CryptoAuthChallengePrompt, challengePrompt),
+		responsePrompt:         valueOr(config.CryptoAuthResponsePrompt, challengeResponsePrompt),
+		helpURL:                config.CryptoAuthHelpURL,
+		qrCode:                 config.CryptoAuthQRCode,
+		inputRetries:           config.CryptoAuthInputRetries,
+		signatureRetries:       config.CryptoAuthSignatureRetries,
+		challengeTTL:           config.CryptoAuthChallengeTTL,
+		timeout:                config.CryptoAuthTimeout,
 		additionalCertCheckers: additionalCertCheckers,
 	}
 	return auth
 }
 
 // Authenticate performs the authentication for the principal.
-func (a *Authenticator) Authenticate(principal string, syslogger *syslog.Writer) error {
-	cert, err := a.readCert()
-	if err != nil {
-		return err
+// The user pastes either a certificate or a plain public key (static key), and signs the challenge with its private key.
+// The user may paste the malformed input again for CryptoAuthInputRetries times in total, and may answer
+// a new challenge for CryptoAuthSignatureRetries times after a challenge fails; the errors wrap ErrMaxTries afterwards.
+// All the input must be read in CryptoAuthTimeout, otherwise the error wraps msg.ErrTimeout.
+func (a *Authenticator) Authenticate(principal string) error {
+	if a.timeout > 0 {
+		a.prompter.SetDeadline(time.Now().Add(a.timeout))
+		defer a.prompter.SetDeadline(time.Time{})
 	}
-	if err := a.validateCert(cert, principal); err != nil {
-		return fmt.Errorf("certificate validation failed, err: %v", err)
+	// inputRetries is the remaining retries for the malformed input, shared by all the reads.
+	inputRetries := a.inputRetries
+
+	// The nonce of the authentication is passed to the client command, and starts the nonces of the challenges.
+	ctx := a.validator.ChallengeContext(principal)
+	ctx.Nonce = make([]byte, authNonceSize)
+	if _, err := rand.Read(ctx.Nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce, err: %v", err)
 	}
-	msg.Printf("\ncertificate verified\n")
 
-	ch, err := challenge.NewChallenge(cert)
+	keys, err := a.readKeys(ctx, &inputRetries)
 	if err != nil {
-		return fmt.Errorf("failed to generate Challenge, err: %v", err)
+		return err
 	}
-	cReq, err := ch.ChallengeRequest()
+	pub, err := a.selectKey(keys, principal)
 	if err != nil {
-		return fmt.Errorf("failed to generate Challenge data, err: %v", err)
+		return err
+	}
+	cert, isCert := pub.(*ssh.Certificate)
+	if isCert {
+		msg.Printf("\ncertificate verified\n")
+	} else {
+		msg.Printf("\npublic key verified\n")
+	}
+	if len(keys) > 1 {
+		msg.Printf("Challenging %s\n", describeKey(pub))
 	}
 
-	a.prompter.Promptf("%s\n%s\n", challengePrompt, cReq)
-	a.prompter.Prompt(challengeResponsePrompt)
+	if isCert {
+		if message := a.validator.Prompt(cert); message != "" {
+			msg.Printf("%s\n", message)
+		}
+	}
+	var ch *challenge.Challenge
+	for retries := a.signatureRetries; ; retries-- {
+		ch, err = a.challenge(pub, ctx, &inputRetries)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errVerification) {
+			return err
+		}
+		if retries == 0 {
+			return fmt.Errorf("%w: %v", ErrMaxTries, err)
+		}
+		msg.Printf("\n"+retryChallengePrompt+"\n", err, retries)
+	}
 
-	cResp, err := a.prompter.ReadString()
+	grant := &audit.Event{Type: audit.TypeGrant, Reason: audit.ReasonStaticKey, User: principal, Details: map[string]string{"method": "cryptoauth"}}
+	grant.SetKey(pub)
+	if isCert {
+		if err := a.validator.Approve(cert); err != nil {
+			return err
+		}
+		grant.Reason = audit.ReasonCertificate
+	}
+	r, err := receipt.New(ch)
 	if err != nil {
 		return err
 	}
-	if err := ch.VerifyResponse(cResp); err != nil {
-		return fmt.Errorf("failed to verify Challenge")
+	if err := a.validator.SaveReceipt(r); err != nil {
+		return err
 	}
 	msg.Printf("\nauthentication successful.\n")
-	if syslogger != nil {
-		if err := syslogger.Info(fmt.Sprintf("Grant: USER=%s, KEYID=(%s)", principal, cert.KeyId)); err != nil {
-			return fmt.Errorf("syslog write failed, err: %v", err)
-		}
+	if err := a.validator.Record(grant); err != nil {
+		msg.Printlf(msg.WARN, "Failed to record audit event: %v", err)
 	}
 	return nil
 }
 
-func (a *Authenticator) readCert() (*ssh.Certificate, error) {
-	clientCmd := fmt.Sprintf(clientCommand, a.clientArgs)
-	a.prompter.Promptf("%s\n\n\t%s\n", clientCommandPrompt, clientCmd)
-	certStr, err := a.prompter.ReadString()
+// challenge issues a new challenge bound to the context for the public key, and returns it after verifying
+// the response pasted by the user.
+// The error wraps errVerification if the response fails the verification, or the challenge expires.
+func (a *Authenticator) challenge(pub ssh.PublicKey, ctx challenge.Context, inputRetries *int) (*challenge.Challenge, error) {
+	ch, err := challenge.NewContextChallenge(pub, ctx, a.challengeTTL)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to generate Challenge, err: %v", err)
 	}
-	keys, _, err := key.GetPublicKeysFromBytes([]byte(certStr))
+	cReq, err := ch.ArmoredChallengeRequest()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read certificates, err: %v", err)
+		return nil, fmt.Errorf("failed to generate Challenge data, err: %v", err)
 	}
 
-	cert, err := key.CastSSHPublicKeyToCertificate(keys[0])
+	a.prompter.Promptf("%s\n%s\n", a.challengePrompt, cReq)
+	// The serialized challenge request is shorter than the armored one, and cryptoauth-client accepts both.
+	if serialized, err := ch.ChallengeRequest(); err == nil {
+		a.printQRCode(serialized)
+	}
+	a.prompter.Promptf("%s\n\n\t%s\n", sshsigPrompt, fmt.Sprintf(sshsigCommand, ch.SSHSigMessage(), challenge.Namespace))
+	a.prompter.Prompt(a.responsePrompt)
+
+	for {
+		cResp, err := a.prompter.ReadBlock()
+		if err != nil {
+			return nil, err
+		}
+		// The following lines of unarmored keys pasted together are read after the first line.
+		// They are not responses, so they don't consume the retries.
+		if keys, err := parseKeys(cResp); err == nil && !armor.IsArmored(cResp) {
+			msg.Printf("\n"+extraKeyPrompt+"\n", describeKey(keys[0]))
+			continue
+		}
+		err = ch.VerifyResponse(cResp)
+		if err == nil {
+			return ch, nil
+		}
+		if !errors.Is(err, challenge.ErrMalformed) {
+			return nil, fmt.Errorf("%w, err: %v", errVerification, err)
+		}
+		if err := retryInput(err, inputRetries); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readKeys asks the user to run the client command, and reads the certificates or the public keys pasted by the user.
+func (a *Authenticator) readKeys(ctx challenge.Context, inputRetries *int) ([]ssh.PublicKey, error) {
+	clientCmd := a.clientCommandLine(ctx)
+	a.prompter.Promptf("%s\n\n\t%s\n", a.clientPrompt, clientCmd)
+	if a.helpURL != "" {
+		msg.Printf(helpURLPrompt+"\n", a.helpURL)
+	}
+	a.printQRCode([]byte(clientCmd))
+	for {
+		keyStr, err := a.prompter.ReadBlock()
+		if err != nil {
+			return nil, err
+		}
+		keys, err := parseKeys(keyStr)
+		if err == nil {
+			return keys, nil
+		}
+		if err := retryInput(err, inputRetries); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// clientCommandLine returns the client command for the user to run, which replaces the tokens
+// in the template of the client command by the context of the authentication request.
+func (a *Authenticator) clientCommandLine(ctx challenge.Context) string {
+	r := strings.NewReplacer("%%", "%", "%u", ctx.User, "%h", ctx.Host, "%s", ctx.Service, "%n", hex.EncodeToString(ctx.Nonce))
+	cmd := r.Replace(a.clientCommand)
+	if a.clientArgs != "" {
+		cmd += " " + a.clientArgs
+	}
+	return cmd
+}
+
+// valueOr returns value, or def if value is empty.
+func valueOr(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
+
+// selectKey returns the first valid key in the pasted keys, with the same ranking as the ssh-agent path:
+// the static keys are preferred over the certificates, and the keys of the same kind are in the pasted order.
+// If no key is valid, the error lists the reasons of all the keys.
+func (a *Authenticator) selectKey(keys []ssh.PublicKey, principal string) (ssh.PublicKey, error) {
+	var static, certs []int
+	for i, pub := range keys {
+		if _, ok := pub.(*ssh.Certificate); ok {
+			certs = append(certs, i)
+		} else {
+			static = append(static, i)
+		}
+	}
+
+	var reasons []string
+	for _, i := range append(static, certs...) {
+		err := a.validateKey(keys[i], principal)
+		if err == nil {
+			return keys[i], nil
+		}
+		if len(keys) > 1 {
+			err = fmt.Errorf("key %d (%s): %v", i+1, describeKey(keys[i]), err)
+		}
+		msg.Printlf(msg.DEBUG, "Pasted %v", err)
+		reasons = append(reasons, err.Error())
+	}
+	return nil, errors.New(strings.Join(reasons, "; "))
+}
+
+// validateKey returns nil if the certificate or the static key is valid for the principal.
+func (a *Authenticator) validateKey(pub ssh.PublicKey, principal string) error {
+	if cert, ok := pub.(*ssh.Certificate); ok {
+		if err := a.validateCert(cert, principal); err != nil {
+			return fmt.Errorf("certificate validation failed, err: %v", err)
+		}
+		return nil
+	}
+	if err := a.validator.CheckStaticKey(pub); err != nil {
+		return fmt.Errorf("public key validation failed, err: %v", err)
+	}
+	return nil
+}
+
+// describeKey returns the description of the key for the user to tell the pasted keys apart.
+func describeKey(pub ssh.PublicKey) string {
+	if cert, ok := pub.(*ssh.Certificate); ok {
+		return fmt.Sprintf("certificate %s, key ID: %s", ssh.FingerprintSHA256(cert.Key), cert.KeyId)
+	}
+	return fmt.Sprintf("public key %s", ssh.FingerprintSHA256(pub))
+}
+
+// parseKeys returns the public keys and certificates in the pasted input, which is optionally armored.
+// An armored input may carry a bundle of keys, one per line.
+func parseKeys(keyStr string) ([]ssh.PublicKey, error) {
+	keyBytes := []byte(keyStr)
+	if armor.IsArmored(keyStr) {
+		var err error
+		if keyBytes, err = armor.Decode(certificateType, keyStr); err != nil {
+			return nil, fmt.Errorf("failed to read certificate, err: %v", err)
+		}
+	}
+	keys, _, err := key.GetPublicKeysFromBytes(keyBytes)
 	if err != nil {
-		return nil, fmt.Errorf("failed to cast public key to Certificate, err: %v", err)
+		return nil, fmt.Errorf("failed to read public keys, err: %v", err)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no public key found")
+	}
+	return keys, nil
+}
+
+// retryInput asks the user to paste the malformed input again, and consumes one of the remaining retries.
+// It returns an error wrapping ErrMaxTries if no retry remains.
+func retryInput(cause error, inputRetries *int) error {
+	if *inputRetries <= 0 {
+		return fmt.Errorf("%w: %v", ErrMaxTries, cause)
 	}
-	return cert, nil
+	*inputRetries--
+	msg.Printf("\n"+retryInputPrompt+"\n", cause, *inputRetries)
+	return nil
 }
 
 // validateCert validates certificate signed by crypki servers.
 // For validation to succeed the certificate must be
+// - valid for the validation pipeline shared with the ssh-agent path.
 // - all the additional cert checkers pass the check.
-// - the ssh cert checker pass the check.
-// - the signature key matches to the user authority.
 func (a *Authenticator) validateCert(cert *ssh.Certificate, principal string) error {
+	if err := a.validator.CheckCert(cert, principal); err != nil {
+		return err
+	}
 	for _, checker := range a.additionalCertCheckers {
 		if err := checker.CheckCert(cert, principal); err != nil {
 			return fmt.Errorf("certificate check failed, err: %v", err)
 		}
 	}
+	return nil
+}
 
-	// Validate revocation, timestamp, validPrincipals,  and
-	// the signature of the certificate.
-	if err := a.CertChecker.CheckCert(principal, cert); err != nil {
-		return err
+// printQRCode prints the data as a QR code if CryptoAuthQRCode is enabled.
+// The data is always printed as plain text as well, so the failure to render the QR code is not fatal.
+func (a *Authenticator) printQRCode(data []byte) {
+	if !a.qrCode {
+		return
 	}
-	// Verify if the certificate is indeed signed by the CA.
-	if !a.CertChecker.IsUserAuthority(cert.SignatureKey) {
-		return fmt.Errorf("certificate signed by unrecognized authority")
+	code, err := qrcode.Encode(data, qrcode.Low)
+	if err != nil {
+		msg.Printlf(msg.DEBUG, "Failed to render QR code, err: %v", err)
+		return
 	}
-	return nil
+	msg.Printf("\n%s", code.Terminal())
 }
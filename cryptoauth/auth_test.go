@@ -0,0 +1,503 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package cryptoauth
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/theparanoids/pam-ysshca/audit"
+	"github.com/theparanoids/pam-ysshca/conf"
+	"github.com/theparanoids/pam-ysshca/msg"
+	"github.com/theparanoids/pam-ysshca/sshutils/armor"
+	"github.com/theparanoids/pam-ysshca/sshutils/challenge"
+	"github.com/theparanoids/pam-ysshca/sshutils/receipt"
+	"golang.org/x/crypto/ssh"
+)
+
+// fakeValidator accepts all the static keys.
+type fakeValidator struct{}
+
+func (fakeValidator) CheckCert(*ssh.Certificate, string) error { return nil }
+func (fakeValidator) CheckStaticKey(ssh.PublicKey) error       { return nil }
+func (fakeValidator) ChallengeContext(principal string) challenge.Context {
+	return challenge.Context{Host: "host", User: principal}
+}
+func (fakeValidator) Prompt(*ssh.Certificate) string     { return "" }
+func (fakeValidator) Prompter() *msg.Prompter            { return nil }
+func (fakeValidator) Approve(*ssh.Certificate) error     { return nil }
+func (fakeValidator) SaveReceipt(*receipt.Receipt) error { return nil }
+func (fakeValidator) Record(*audit.Event) error          { return nil }
+
+const (
+	answerGood = "good"
+	answerBad  = "bad"
+)
+
+// fakeUser answers the challenges printed by the Authenticator, in place of the user.
+type fakeUser struct {
+	t      *testing.T
+	input  *os.File
+	signer ssh.Signer
+	other  ssh.Signer
+	// answers are the answers to the challenges in order. Each answer is a list of inputs,
+	// which are answerGood, answerBad (signed by another key), or malformed input pasted as it is.
+	answers [][]string
+
+	mu         sync.Mutex
+	output     bytes.Buffer
+	challenges int
+}
+
+// Write receives the output of the Authenticator, and answers each challenge in it.
+func (u *fakeUser) Write(p []byte) (int, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.output.Write(p)
+	for {
+		out := u.output.String()
+		begin := strings.Index(out, armor.Begin(challenge.RequestType))
+		end := strings.Index(out, armor.End(challenge.RequestType))
+		if begin < 0 || end < begin {
+			return len(p), nil
+		}
+		end += len(armor.End(challenge.RequestType))
+		req := out[begin:end]
+		u.output.Next(end)
+
+		var answer []string
+		if u.challenges < len(u.answers) {
+			answer = u.answers[u.challenges]
+		}
+		u.challenges++
+		var inputs []string
+		for _, a := range answer {
+			switch a {
+			case answerGood:
+				inputs = append(inputs, u.respond(u.signer, req))
+			case answerBad:
+				inputs = append(inputs, u.respond(u.other, req))
+			default:
+				inputs = append(inputs, a)
+			}
+		}
+		// The Authenticator reads the input after printing the prompts.
+		go u.paste(inputs...)
+	}
+}
+
+// paste writes the inputs to the Authenticator.
+func (u *fakeUser) paste(inputs ...string) {
+	for _, in := range inputs {
+		u.input.Write([]byte(in + "\n")) //nolint:errcheck
+	}
+}
+
+// respond returns the armored response of the challenge request signed by the signer.
+func (u *fakeUser) respond(signer ssh.Signer, req string) string {
+	cd := &challenge.Data{}
+	if err := cd.UnmarshalArmored(challenge.RequestType, req); err != nil {
+		u.t.Error(err)
+		return ""
+	}
+	sig, err := signer.Sign(rand.Reader, cd.Data)
+	if err != nil {
+		u.t.Error(err)
+		return ""
+	}
+	cd.Signature = *sig
+	resp, err := cd.MarshalArmored(challenge.ResponseType)
+	if err != nil {
+		u.t.Error(err)
+	}
+	return resp
+}
+
+func newTestSigner(t *testing.T) ssh.Signer {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func TestAuthenticator_Authenticate(t *testing.T) {
+	// Disable parallel because we temporarily redirect the writer.
+	defer msg.SetWriter(os.Stderr)
+
+	signer, other := newTestSigner(t), newTestSigner(t)
+	pubKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey())))
+	otherPubKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(other.PublicKey())))
+
+	tests := []struct {
+		name             string
+		keyInputs        []string
+		answers          [][]string
+		inputRetries     int
+		signatureRetries int
+		challengeTTL     time.Duration
+		timeout          time.Duration
+		wantChallenges   int
+		wantErr          error
+	}{
+		{
+			name:           "success",
+			keyInputs:      []string{pubKey},
+			answers:        [][]string{{answerGood}},
+			inputRetries:   2,
+			challengeTTL:   time.Minute,
+			wantChallenges: 1,
+		},
+		{
+			name:           "malformed key and response retried",
+			keyInputs:      []string{"garbage", pubKey},
+			answers:        [][]string{{"garbage", answerGood}},
+			inputRetries:   2,
+			challengeTTL:   time.Minute,
+			wantChallenges: 1,
+		},
+		{
+			// The second line of the unarmored paste is skipped, rather than read as a malformed response.
+			name:           "unarmored multi-line paste",
+			keyInputs:      []string{pubKey + "\n" + otherPubKey},
+			answers:        [][]string{{answerGood}},
+			challengeTTL:   time.Minute,
+			wantChallenges: 1,
+		},
+		{
+			name:           "malformed inputs exceed retries",
+			keyInputs:      []string{"garbage", pubKey},
+			answers:        [][]string{{"garbage", answerGood}},
+			inputRetries:   1,
+			challengeTTL:   time.Minute,
+			wantChallenges: 1,
+			wantErr:        ErrMaxTries,
+		},
+		{
+			name:             "bad signature retried with a new challenge",
+			keyInputs:        []string{pubKey},
+			answers:          [][]string{{answerBad}, {answerGood}},
+			signatureRetries: 1,
+			challengeTTL:     time.Minute,
+			wantChallenges:   2,
+		},
+		{
+			name:             "bad signatures exceed retries",
+			keyInputs:        []string{pubKey},
+			answers:          [][]string{{answerBad}, {answerBad}},
+			signatureRetries: 1,
+			challengeTTL:     time.Minute,
+			wantChallenges:   2,
+			wantErr:          ErrMaxTries,
+		},
+		{
+			name:             "expired challenges",
+			keyInputs:        []string{pubKey},
+			answers:          [][]string{{answerGood}, {answerGood}},
+			signatureRetries: 1,
+			wantChallenges:   2,
+			wantErr:          ErrMaxTries,
+		},
+		{
+			name:           "timeout on a half-finished paste",
+			keyInputs:      []string{pubKey},
+			answers:        [][]string{{armor.Begin(challenge.ResponseType)}},
+			challengeTTL:   time.Minute,
+			timeout:        200 * time.Millisecond,
+			wantChallenges: 1,
+			wantErr:        msg.ErrTimeout,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The input is a file, as the terminal, on which the Prompter enforces the timeout.
+			in, out, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer in.Close()
+			defer out.Close()
+			user := &fakeUser{t: t, input: out, signer: signer, other: other, answers: tt.answers}
+			msg.SetWriter(user)
+			go user.paste(tt.keyInputs...)
+
+			a := &Authenticator{
+				validator:        fakeValidator{},
+				prompter:         msg.NewPrompterWithReader(in),
+				inputRetries:     tt.inputRetries,
+				signatureRetries: tt.signatureRetries,
+				challengeTTL:     tt.challengeTTL,
+				timeout:          tt.timeout,
+			}
+			err = a.Authenticate("alice")
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("Authenticate() error = %v, want %v", err, tt.wantErr)
+			}
+			user.mu.Lock()
+			defer user.mu.Unlock()
+			if user.challenges != tt.wantChallenges {
+				t.Errorf("Authenticate() issued %d challenges, want %d", user.challenges, tt.wantChallenges)
+			}
+		})
+	}
+}
+
+func TestAuthenticator_clientCommandLine(t *testing.T) {
+	t.Parallel()
+	ctx := challenge.Context{User: "alice", Host: "host1", Service: "sudo", Nonce: []byte{0xab, 0xcd}}
+	tests := []struct {
+		name       string
+		command    string
+		clientArgs string
+		want       string
+	}{
+		{
+			name:    "default command",
+			command: clientCommand,
+			want:    "cryptoauth-client -u alice -n abcd",
+		},
+		{
+			name:    "all tokens",
+			command: "my-client --user %u --host %h --service %s --nonce %n --literal 100%%u",
+			want:    "my-client --user alice --host host1 --service sudo --nonce abcd --literal 100%u",
+		},
+		{
+			name:       "client args appended",
+			command:    clientCommand,
+			clientArgs: "-v",
+			want:       "cryptoauth-client -u alice -n abcd -v",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			a := &Authenticator{clientCommand: tt.command, clientArgs: tt.clientArgs}
+			if got := a.clientCommandLine(ctx); got != tt.want {
+				t.Errorf("clientCommandLine() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// justifyingValidator reads the justification in Approve from the shared Prompter, as the firefighter certificates do.
+type justifyingValidator struct {
+	fakeValidator
+	prompter      *msg.Prompter
+	justification string
+}
+
+func (v *justifyingValidator) Prompter() *msg.Prompter { return v.prompter }
+
+func (v *justifyingValidator) Approve(*ssh.Certificate) error {
+	justification, err := v.prompter.ReadString()
+	if err != nil {
+		return err
+	}
+	v.justification = justification
+	return nil
+}
+
+func TestAuthenticator_Authenticate_firefighter(t *testing.T) {
+	// Disable parallel because we temporarily redirect the writer.
+	defer msg.SetWriter(os.Stderr)
+
+	signer, ca := newTestSigner(t), newTestSigner(t)
+	cert := &ssh.Certificate{
+		Key:             signer.PublicKey(),
+		KeyId:           "firefighter",
+		CertType:        ssh.UserCert,
+		ValidPrincipals: []string{"alice"},
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatal(err)
+	}
+
+	in, out, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	defer out.Close()
+	user := &fakeUser{t: t, input: out, signer: signer, answers: [][]string{{answerGood, "INC-123 database outage"}}}
+	msg.SetWriter(user)
+	go user.paste(strings.TrimSpace(string(ssh.MarshalAuthorizedKey(cert))))
+
+	v := &justifyingValidator{prompter: msg.NewPrompterWithReader(in)}
+	a := NewAuthenticator(conf.Config{CryptoAuthChallengeTTL: time.Minute, CryptoAuthTimeout: time.Minute}, "", v)
+	if a.prompter != v.prompter {
+		t.Fatalf("NewAuthenticator() doesn't share the Prompter of the validator")
+	}
+	if err := a.Authenticate("alice"); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	// The timeout of cryptoauth must not lose the justification read by Approve.
+	if v.justification != "INC-123 database outage" {
+		t.Errorf("Approve() read justification %q, want %q", v.justification, "INC-123 database outage")
+	}
+}
+
+// rejectingValidator rejects the certificates with the key IDs in rejectedCerts, and the static keys if rejectStatic is set.
+type rejectingValidator struct {
+	fakeValidator
+	rejectedCerts map[string]bool
+	rejectStatic  bool
+}
+
+func (v rejectingValidator) CheckCert(cert *ssh.Certificate, _ string) error {
+	if v.rejectedCerts[cert.KeyId] {
+		return errors.New("certificate expired")
+	}
+	return nil
+}
+
+func (v rejectingValidator) CheckStaticKey(ssh.PublicKey) error {
+	if v.rejectStatic {
+		return errors.New("static keys are not allowed")
+	}
+	return nil
+}
+
+func newTestCert(t *testing.T, keyID string) *ssh.Certificate {
+	cert := &ssh.Certificate{
+		Key:             newTestSigner(t).PublicKey(),
+		KeyId:           keyID,
+		CertType:        ssh.UserCert,
+		ValidPrincipals: []string{"alice"},
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+	if err := cert.SignCert(rand.Reader, newTestSigner(t)); err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestAuthenticator_selectKey(t *testing.T) {
+	t.Parallel()
+	expired, valid, other := newTestCert(t, "expired"), newTestCert(t, "valid"), newTestCert(t, "other")
+	static := newTestSigner(t).PublicKey()
+
+	tests := []struct {
+		name      string
+		validator rejectingValidator
+		keys      []ssh.PublicKey
+		want      ssh.PublicKey
+		wantErr   []string
+	}{
+		{
+			name:      "first cert expired",
+			validator: rejectingValidator{rejectedCerts: map[string]bool{"expired": true}},
+			keys:      []ssh.PublicKey{expired, valid, other},
+			want:      valid,
+		},
+		{
+			name:      "static key preferred",
+			validator: rejectingValidator{},
+			keys:      []ssh.PublicKey{valid, static},
+			want:      static,
+		},
+		{
+			name:      "certificate if static keys rejected",
+			validator: rejectingValidator{rejectStatic: true},
+			keys:      []ssh.PublicKey{static, valid},
+			want:      valid,
+		},
+		{
+			name:      "single invalid key",
+			validator: rejectingValidator{rejectedCerts: map[string]bool{"expired": true}},
+			keys:      []ssh.PublicKey{expired},
+			wantErr:   []string{"certificate validation failed, err: certificate expired"},
+		},
+		{
+			name:      "all keys invalid",
+			validator: rejectingValidator{rejectedCerts: map[string]bool{"expired": true, "other": true}, rejectStatic: true},
+			keys:      []ssh.PublicKey{expired, other, static},
+			wantErr:   []string{"key 1 (certificate", "key ID: expired)", "key 2 (", "key 3 (public key", "static keys are not allowed"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			a := &Authenticator{validator: tt.validator}
+			got, err := a.selectKey(tt.keys, "alice")
+			if tt.wantErr != nil {
+				if err == nil {
+					t.Fatalf("selectKey() = %v, want error", describeKey(got))
+				}
+				for _, want := range tt.wantErr {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("selectKey() error = %v, want %q in it", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("selectKey() error = %v", err)
+			}
+			if !bytes.Equal(got.Marshal(), tt.want.Marshal()) {
+				t.Errorf("selectKey() = %v, want %v", describeKey(got), describeKey(tt.want))
+			}
+		})
+	}
+}
+
+func Test_parseKeys(t *testing.T) {
+	t.Parallel()
+	first, second := newTestCert(t, "first"), newTestCert(t, "second")
+	bundle := append(ssh.MarshalAuthorizedKey(first), ssh.MarshalAuthorizedKey(second)...)
+
+	tests := []struct {
+		name    string
+		input   string
+		want    []*ssh.Certificate
+		wantErr bool
+	}{
+		{
+			name:  "plain certificate",
+			input: string(ssh.MarshalAuthorizedKey(first)),
+			want:  []*ssh.Certificate{first},
+		},
+		{
+			name:  "armored bundle",
+			input: armor.Encode(certificateType, bundle),
+			want:  []*ssh.Certificate{first, second},
+		},
+		{
+			name:    "garbage",
+			input:   "garbage",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := parseKeys(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseKeys() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseKeys() returned %d keys, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if !bytes.Equal(got[i].Marshal(), tt.want[i].Marshal()) {
+					t.Errorf("parseKeys()[%d] = %v, want %v", i, describeKey(got[i]), describeKey(tt.want[i]))
+				}
+			}
+		})
+	}
+}
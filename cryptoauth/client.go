@@ -0,0 +1,221 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package cryptoauth
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/theparanoids/pam-ysshca/msg"
+	"github.com/theparanoids/pam-ysshca/sshutils/armor"
+	"github.com/theparanoids/pam-ysshca/sshutils/challenge"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+const (
+	clientCertPrompt      = "Paste the following certificates in the server's window:"
+	clientChallengePrompt = "Paste the challenge from the server: "
+	clientResponsePrompt  = "Paste the following response in the server's window:"
+	clientApprovePrompt   = "The server requests your approval of USER=%s, TARGET=%s, HOST=%s, SERVICE=%s, CMD=(%s)\nSign the challenge? [y/N]: "
+)
+
+// Client is the client of ASCII Crypto Challenge, which runs on the user's computer with the ssh-agent.
+// It prints the certificates of the user, and signs the challenge from the server by the ssh-agent.
+type Client struct {
+	agent    agent.Agent
+	prompter *msg.Prompter
+	out      io.Writer
+	now      func() time.Time
+	nonce    []byte
+}
+
+// NewClient returns a new Client, which signs the challenges by the ssh-agent,
+// reads the challenges from in, and prints the certificates and the responses to out.
+func NewClient(ag agent.Agent, in io.Reader, out io.Writer) *Client {
+	return &Client{
+		agent:    ag,
+		prompter: msg.NewPrompterWithReader(in),
+		out:      out,
+		now:      time.Now,
+	}
+}
+
+// SetNonce sets the hex encoded nonce of the authentication, which is printed in the client command by the server.
+// Afterwards, the client signs only the challenges of that authentication.
+func (c *Client) SetNonce(nonce string) error {
+	b, err := hex.DecodeString(nonce)
+	if err != nil {
+		return fmt.Errorf("invalid nonce, err: %v", err)
+	}
+	c.nonce = b
+	return nil
+}
+
+// Run prints the certificates in one armored block, the ones for the principal first, reads the challenge,
+// and prints the signed response.
+// The certificates are looked up in the ssh-agent, and then in the certificate files.
+// The server challenges the first certificate in the block that it accepts.
+func (c *Client) Run(principal string, certFiles []string) error {
+	certs, err := c.FindCertificates(principal, certFiles)
+	if err != nil {
+		return err
+	}
+	var bundle []byte
+	for _, cert := range certs {
+		bundle = append(bundle, ssh.MarshalAuthorizedKey(cert)...)
+	}
+	c.prompter.Prompt(clientCertPrompt)
+	fmt.Fprintf(c.out, "%s\n", armor.Encode(certificateType, bundle))
+
+	c.prompter.Prompt(clientChallengePrompt)
+	req, err := c.prompter.ReadBlock()
+	if err != nil {
+		return err
+	}
+	resp, err := c.Respond(certs, req)
+	if err != nil {
+		return err
+	}
+	c.prompter.Prompt(clientResponsePrompt)
+	fmt.Fprintf(c.out, "%s\n", resp)
+	return nil
+}
+
+// FindCertificates returns the valid user certificates, and the ones with the principal are ordered first.
+// The certificates without the principal are returned as well, because the server may accept them by other
+// principals of the user, such as the group principals or the mapped principals.
+// Otherwise, the certificates in the ssh-agent are ordered before the ones in the certificate files,
+// whose private keys must be loaded in the ssh-agent.
+func (c *Client) FindCertificates(principal string, certFiles []string) ([]*ssh.Certificate, error) {
+	var certs []*ssh.Certificate
+	identities, err := c.agent.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list identities in ssh-agent, err: %v", err)
+	}
+	for _, id := range identities {
+		pub, err := ssh.ParsePublicKey(id.Blob)
+		if err != nil {
+			continue
+		}
+		if cert, ok := pub.(*ssh.Certificate); ok {
+			certs = append(certs, cert)
+		}
+	}
+	for _, file := range certFiles {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			msg.Printlf(msg.DEBUG, "Failed to read certificate file %s, err: %v", file, err)
+			continue
+		}
+		pub, _, _, _, err := ssh.ParseAuthorizedKey(data)
+		if err != nil {
+			msg.Printlf(msg.DEBUG, "Failed to parse certificate file %s, err: %v", file, err)
+			continue
+		}
+		if cert, ok := pub.(*ssh.Certificate); ok {
+			certs = append(certs, cert)
+		}
+	}
+
+	now := uint64(c.now().Unix())
+	var matched, others []*ssh.Certificate
+	seen := make(map[string]bool)
+	for _, cert := range certs {
+		if cert.CertType != ssh.UserCert || now < cert.ValidAfter || now >= cert.ValidBefore {
+			continue
+		}
+		// The certificate in the ssh-agent may be in the certificate files as well.
+		fp := ssh.FingerprintSHA256(cert)
+		if seen[fp] {
+			continue
+		}
+		seen[fp] = true
+		if principal != "" && hasPrincipal(cert, principal) {
+			matched = append(matched, cert)
+		} else {
+			others = append(others, cert)
+		}
+	}
+	if valid := append(matched, others...); len(valid) != 0 {
+		return valid, nil
+	}
+	return nil, fmt.Errorf("no valid certificate found")
+}
+
+// Respond returns the armored response of the serialized or armored challenge request,
+// which is signed by the private key of the certificate in the ssh-agent that the server challenges.
+// The challenge must be a context that has not expired, which the user approves before it is signed,
+// so that the client doesn't sign arbitrary data for the host that sends the challenge.
+// If the nonce is set, the nonce of the context must start with it.
+func (c *Client) Respond(certs []*ssh.Certificate, req string) (string, error) {
+	cd := &challenge.Data{}
+	if armor.IsArmored(req) {
+		if err := cd.UnmarshalArmored(challenge.RequestType, req); err != nil {
+			return "", fmt.Errorf("failed to parse challenge, err: %v", err)
+		}
+	} else if err := cd.Unmarshal(bytes.TrimSpace([]byte(req))); err != nil {
+		return "", fmt.Errorf("failed to parse challenge, err: %v", err)
+	}
+	ctx, err := challenge.ParseContext(cd.Data)
+	if err != nil {
+		return "", fmt.Errorf("refusing to sign data that is not a PAM-SSHCA challenge, err: %v", err)
+	}
+	if expiry := time.Unix(ctx.ExpiresAt, 0); !c.now().Before(expiry) {
+		return "", fmt.Errorf("refusing to sign challenge that expired at %s", expiry.Format(time.RFC3339))
+	}
+	if !bytes.HasPrefix(ctx.Nonce, c.nonce) {
+		return "", fmt.Errorf("refusing to sign challenge of another authentication")
+	}
+	cert, err := challengedCert(certs, ctx.Key)
+	if err != nil {
+		return "", err
+	}
+
+	// Show the user what the signature approves, which is recorded in the receipt of the grant.
+	c.prompter.Promptf(clientApprovePrompt, ctx.User, ctx.Target, ctx.Host, ctx.Service, ctx.Command)
+	answer, err := c.prompter.ReadString()
+	if err != nil {
+		return "", err
+	}
+	if answer = strings.ToLower(answer); answer != "y" && answer != "yes" {
+		return "", fmt.Errorf("challenge is not approved")
+	}
+
+	sig, err := challenge.SignWithAgent(c.agent, cert, cd.Data)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign challenge by ssh-agent, err: %v", err)
+	}
+	cd.Signature = *sig
+	return cd.MarshalArmored(challenge.ResponseType)
+}
+
+// challengedCert returns the certificate with the fingerprint of the challenged key.
+// The challenges without the fingerprint are accepted only if there is a single certificate.
+func challengedCert(certs []*ssh.Certificate, fingerprint string) (*ssh.Certificate, error) {
+	for _, cert := range certs {
+		if ssh.FingerprintSHA256(cert) == fingerprint {
+			return cert, nil
+		}
+	}
+	if fingerprint == "" && len(certs) == 1 {
+		return certs[0], nil
+	}
+	return nil, fmt.Errorf("the challenged key %s is not one of the pasted certificates", fingerprint)
+}
+
+// hasPrincipal returns true if the certificate is valid for the principal.
+func hasPrincipal(cert *ssh.Certificate, principal string) bool {
+	for _, p := range cert.ValidPrincipals {
+		if p == principal {
+			return true
+		}
+	}
+	return false
+}
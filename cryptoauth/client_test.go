@@ -0,0 +1,390 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package cryptoauth
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/theparanoids/pam-ysshca/sshutils/armor"
+	"github.com/theparanoids/pam-ysshca/sshutils/challenge"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// testCert returns a user certificate of a new private key valid for the principals from validAfter to validBefore.
+func testCert(t *testing.T, priv interface{}, validAfter, validBefore time.Time, prins ...string) *ssh.Certificate {
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, caPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caSigner, err := ssh.NewSignerFromKey(caPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := &ssh.Certificate{
+		Key:             signer.PublicKey(),
+		KeyId:           "keyID",
+		CertType:        ssh.UserCert,
+		ValidPrincipals: prins,
+		ValidAfter:      uint64(validAfter.Unix()),
+		ValidBefore:     uint64(validBefore.Unix()),
+	}
+	if err := cert.SignCert(rand.Reader, caSigner); err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestClient_FindCertificates(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agentCert := testCert(t, priv, now.Add(-time.Hour), now.Add(time.Hour), "alice")
+	expiredCert := testCert(t, priv, now.Add(-2*time.Hour), now.Add(-time.Hour), "bob")
+	fileCert := testCert(t, priv, now.Add(-time.Hour), now.Add(time.Hour), "bob")
+
+	ag := agent.NewKeyring()
+	for _, cert := range []*ssh.Certificate{agentCert, expiredCert} {
+		if err := ag.Add(agent.AddedKey{PrivateKey: priv, Certificate: cert}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	certFile := filepath.Join(t.TempDir(), "id_ed25519-cert.pub")
+	if err := os.WriteFile(certFile, ssh.MarshalAuthorizedKey(fileCert), 0600); err != nil {
+		t.Fatal(err)
+	}
+	agentCertFile := filepath.Join(t.TempDir(), "id_agent-cert.pub")
+	if err := os.WriteFile(agentCertFile, ssh.MarshalAuthorizedKey(agentCert), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(ag, strings.NewReader(""), &bytes.Buffer{})
+
+	tests := []struct {
+		name      string
+		principal string
+		certFiles []string
+		want      []*ssh.Certificate
+	}{
+		{
+			name:      "certificate in ssh-agent",
+			principal: "alice",
+			certFiles: []string{certFile},
+			want:      []*ssh.Certificate{agentCert, fileCert},
+		},
+		{
+			name:      "any principal",
+			certFiles: []string{certFile},
+			want:      []*ssh.Certificate{agentCert, fileCert},
+		},
+		{
+			name:      "expired certificate in ssh-agent, valid certificate file first",
+			principal: "bob",
+			certFiles: []string{filepath.Join(t.TempDir(), "not-exist-cert.pub"), certFile},
+			want:      []*ssh.Certificate{fileCert, agentCert},
+		},
+		{
+			// The server may accept the certificates by the other principals of the user, such as group principals.
+			name:      "no certificate with the principal",
+			principal: "carol",
+			certFiles: []string{certFile},
+			want:      []*ssh.Certificate{agentCert, fileCert},
+		},
+		{
+			name:      "certificate both in ssh-agent and file",
+			principal: "alice",
+			certFiles: []string{agentCertFile},
+			want:      []*ssh.Certificate{agentCert},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.FindCertificates(tt.principal, tt.certFiles)
+			if err != nil {
+				t.Fatalf("FindCertificates() unexpected error: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("FindCertificates() got %d certificates, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if !bytes.Equal(got[i].Marshal(), tt.want[i].Marshal()) {
+					t.Errorf("FindCertificates()[%d] got certificate for %v, want %v", i, got[i].ValidPrincipals, tt.want[i].ValidPrincipals)
+				}
+			}
+		})
+	}
+
+	// The expired certificate is not valid for any principal.
+	expiredAgent := agent.NewKeyring()
+	if err := expiredAgent.Add(agent.AddedKey{PrivateKey: priv, Certificate: expiredCert}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewClient(expiredAgent, strings.NewReader(""), &bytes.Buffer{}).FindCertificates("bob", nil); err == nil {
+		t.Errorf("FindCertificates() expected error without valid certificates")
+	}
+}
+
+func TestClient_Run(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		priv interface{}
+		// withCert is true if the private key is added to the ssh-agent with the certificate.
+		withCert bool
+	}{
+		{name: "rsa key with certificate", priv: rsaPriv, withCert: true},
+		{name: "ed25519 key with certificate", priv: edPriv, withCert: true},
+		{name: "ed25519 key without certificate", priv: edPriv},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert := testCert(t, tt.priv, now.Add(-time.Hour), now.Add(time.Hour), "alice")
+			ag := agent.NewKeyring()
+			added := agent.AddedKey{PrivateKey: tt.priv}
+			if tt.withCert {
+				added.Certificate = cert
+			}
+			if err := ag.Add(added); err != nil {
+				t.Fatal(err)
+			}
+			certFile := filepath.Join(t.TempDir(), "id-cert.pub")
+			if err := os.WriteFile(certFile, ssh.MarshalAuthorizedKey(cert), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			ch, err := challenge.NewContextChallenge(cert, challenge.Context{User: "alice"}, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, err := ch.ArmoredChallengeRequest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := &bytes.Buffer{}
+			c := NewClient(ag, strings.NewReader(req+"\ny\n"), out)
+			if err := c.Run("alice", []string{certFile}); err != nil {
+				t.Fatalf("Run() unexpected error: %v", err)
+			}
+
+			certBlock, resp, found := strings.Cut(out.String(), armor.End(certificateType))
+			if !found {
+				t.Fatalf("Run() printed no certificate block: %q", out.String())
+			}
+			certBytes, err := armor.Decode(certificateType, certBlock+armor.End(certificateType))
+			if err != nil {
+				t.Fatal(err)
+			}
+			pub, _, _, _, err := ssh.ParseAuthorizedKey(certBytes)
+			if err != nil || !bytes.Equal(pub.Marshal(), cert.Marshal()) {
+				t.Errorf("Run() printed certificate %q, err: %v", certBytes, err)
+			}
+			if err := ch.VerifyResponse(strings.TrimSpace(resp)); err != nil {
+				t.Errorf("Run() printed response that fails verification: %v", err)
+			}
+		})
+	}
+}
+
+func TestClient_Run_bundle(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	ag := agent.NewKeyring()
+	var certs []*ssh.Certificate
+	for i := 0; i < 2; i++ {
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert := testCert(t, priv, now.Add(-time.Hour), now.Add(time.Hour), "alice")
+		if err := ag.Add(agent.AddedKey{PrivateKey: priv, Certificate: cert}); err != nil {
+			t.Fatal(err)
+		}
+		certs = append(certs, cert)
+	}
+
+	// The server challenges the second certificate of the bundle, e.g. if it rejects the first one.
+	ch, err := challenge.NewContextChallenge(certs[1], challenge.Context{User: "alice"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := ch.ArmoredChallengeRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &bytes.Buffer{}
+	c := NewClient(ag, strings.NewReader(req+"\ny\n"), out)
+	if err := c.Run("alice", nil); err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+
+	certBlock, resp, found := strings.Cut(out.String(), armor.End(certificateType))
+	if !found {
+		t.Fatalf("Run() printed no certificate block: %q", out.String())
+	}
+	keys, err := parseKeys(certBlock + armor.End(certificateType))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != len(certs) {
+		t.Fatalf("Run() printed %d certificates in the block, want %d", len(keys), len(certs))
+	}
+	for i := range keys {
+		if !bytes.Equal(keys[i].Marshal(), certs[i].Marshal()) {
+			t.Errorf("Run() printed certificate %d out of order", i)
+		}
+	}
+	if err := ch.VerifyResponse(strings.TrimSpace(resp)); err != nil {
+		t.Errorf("Run() printed response that fails verification: %v", err)
+	}
+}
+
+func TestClient_Respond(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := testCert(t, priv, now.Add(-time.Hour), now.Add(time.Hour), "alice")
+	ag := agent.NewKeyring()
+	if err := ag.Add(agent.AddedKey{PrivateKey: priv, Certificate: cert}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := challenge.Context{Host: "host1", User: "alice", Service: "sudo", Command: "sudo reboot", Nonce: []byte{0xab, 0xcd}}
+	ch, err := challenge.NewContextChallenge(cert, ctx, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serialized, err := ch.ChallengeRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	armored, err := ch.ArmoredChallengeRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := challenge.NewContextChallenge(cert, ctx, -time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiredReq, err := expired.ArmoredChallengeRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A challenge of random data without a context, such as arbitrary data of a malicious host.
+	legacy, err := challenge.NewChallenge(cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacyReq, err := legacy.ArmoredChallengeRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		req     string
+		answer  string
+		nonce   string
+		wantErr string
+	}{
+		{
+			name:   "serialized request",
+			req:    string(serialized),
+			answer: "y",
+		},
+		{
+			name:   "nonce of the authentication",
+			req:    armored,
+			answer: "y",
+			nonce:  "abcd",
+		},
+		{
+			name:    "nonce of another authentication",
+			req:     armored,
+			answer:  "y",
+			nonce:   "1234",
+			wantErr: "another authentication",
+		},
+		{
+			name:   "armored request",
+			req:    armored,
+			answer: "yes",
+		},
+		{
+			name:    "response pasted as request",
+			req:     strings.ReplaceAll(armored, challenge.RequestType, challenge.ResponseType),
+			answer:  "y",
+			wantErr: "expected PAM-SSHCA CHALLENGE block",
+		},
+		{
+			name:    "not approved",
+			req:     armored,
+			answer:  "n",
+			wantErr: "not approved",
+		},
+		{
+			name:    "expired challenge",
+			req:     expiredReq,
+			answer:  "y",
+			wantErr: "expired",
+		},
+		{
+			name:    "data without context",
+			req:     legacyReq,
+			answer:  "y",
+			wantErr: "not a PAM-SSHCA challenge",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			c := NewClient(ag, strings.NewReader(tt.answer+"\n"), out)
+			if err := c.SetNonce(tt.nonce); err != nil {
+				t.Fatal(err)
+			}
+			resp, err := c.Respond([]*ssh.Certificate{cert}, tt.req)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Respond() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Respond() unexpected error: %v", err)
+			}
+			if err := ch.VerifyResponse(resp); err != nil {
+				t.Errorf("Respond() response fails verification: %v", err)
+			}
+		})
+	}
+}
@@ -5,23 +5,88 @@ package msg
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	prefix = ">>>"
+	// beginPrefix, endPrefix and markerSuffix are the parts of the begin and end markers of armored data.
+	beginPrefix  = "-----BEGIN "
+	endPrefix    = "-----END "
+	markerSuffix = "-----"
 )
 
-const prefix = ">>>"
+// ErrTimeout is returned by the Prompter when the user does not finish the input before the deadline.
+var ErrTimeout = errors.New("timed out waiting for input")
 
 // Prompter contains the logic to interact with users.
 type Prompter struct {
 	reader *bufio.Reader
+	input  *input
+}
+
+// input is the input of the Prompter, which waits for the data until the deadline before each read,
+// so that no read outlives the call of the Prompter that issues it.
+type input struct {
+	r io.Reader
+	// fd is the file descriptor of r, or -1 if r is not a file.
+	fd int
+	// deadline is the time before which the input must be read. Zero means no deadline.
+	deadline time.Time
+}
+
+func (in *input) Read(b []byte) (int, error) {
+	if in.fd >= 0 && !in.deadline.IsZero() {
+		if err := waitInput(in.fd, in.deadline); err != nil {
+			return 0, err
+		}
+	}
+	return in.r.Read(b)
+}
+
+// waitInput waits until the file descriptor is ready to read, or returns ErrTimeout after the deadline.
+func waitInput(fd int, deadline time.Time) error {
+	for {
+		timeout := time.Until(deadline)
+		if timeout <= 0 {
+			return ErrTimeout
+		}
+		fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+		n, err := unix.Poll(fds, int((timeout+time.Millisecond-1)/time.Millisecond))
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		// The end or the error of the input is reported by the following read.
+		if n > 0 {
+			return nil
+		}
+	}
 }
 
 // NewPrompter returns a new Prompter.
 func NewPrompter() *Prompter {
-	reader := bufio.NewReader(os.Stdin)
+	return NewPrompterWithReader(os.Stdin)
+}
+
+// NewPrompterWithReader returns a new Prompter that reads input from the given reader.
+func NewPrompterWithReader(r io.Reader) *Prompter {
+	in := &input{r: r, fd: -1}
+	if f, ok := r.(interface{ Fd() uintptr }); ok {
+		in.fd = int(f.Fd())
+	}
 	return &Prompter{
-		reader: reader,
+		reader: bufio.NewReader(in),
+		input:  in,
 	}
 }
 
@@ -35,11 +100,49 @@ func (p *Prompter) Promptf(str string, objs ...interface{}) {
 	p.Prompt(fmt.Sprintf(str, objs...))
 }
 
+// SetDeadline sets the deadline of the following reads from users. Zero means no deadline.
+// The reads after the deadline return ErrTimeout. The deadline applies only if the input is a file, such as the terminal.
+func (p *Prompter) SetDeadline(t time.Time) {
+	p.input.deadline = t
+}
+
+// readLine reads a line from users before the deadline.
+func (p *Prompter) readLine() (string, error) {
+	return p.reader.ReadString('\n')
+}
+
 // ReadString reads input string from users.
 func (p *Prompter) ReadString() (string, error) {
-	str, err := p.reader.ReadString('\n')
+	str, err := p.readLine()
+	if errors.Is(err, ErrTimeout) {
+		return "", err
+	}
 	if err != nil {
 		return "", fmt.Errorf("failed to read input data, err: %v", err)
 	}
 	return strings.TrimSpace(str), nil
 }
+
+// ReadBlock reads input string from users. If the first line is a begin marker, such as
+// "-----BEGIN SSH SIGNATURE-----", it reads the following lines until the matching end marker,
+// and returns all the lines joined by newlines. It is used to read armored data wrapped in multiple lines.
+func (p *Prompter) ReadBlock() (string, error) {
+	str, err := p.ReadString()
+	if err != nil || !strings.HasPrefix(str, beginPrefix) || !strings.HasSuffix(str, markerSuffix) {
+		return str, err
+	}
+	end := endPrefix + strings.TrimPrefix(str, beginPrefix)
+	lines := []string{str}
+	for str != end {
+		l, err := p.readLine()
+		if errors.Is(err, ErrTimeout) {
+			return "", err
+		}
+		str = strings.TrimSpace(l)
+		if err != nil && str != end {
+			return "", fmt.Errorf("input ended before %q, the data may be truncated", end)
+		}
+		lines = append(lines, str)
+	}
+	return strings.Join(lines, "\n"), nil
+}
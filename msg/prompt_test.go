@@ -4,7 +4,12 @@
 package msg
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"strings"
+	"testing"
+	"time"
 )
 
 func ExamplePrompter_Prompt() {
@@ -27,3 +32,57 @@ func ExamplePrompter_Promptf() {
 	// >>> some
 	//  message
 }
+
+func ExamplePrompter_ReadBlock() {
+	p := NewPrompterWithReader(strings.NewReader("-----BEGIN DATA-----\n  abc\r\ndef\n-----END DATA-----\nsingle line\n-----BEGIN DATA-----\nabc\n"))
+	for i := 0; i < 3; i++ {
+		str, err := p.ReadBlock()
+		fmt.Printf("%q %v\n", str, err)
+	}
+	// Output:
+	// "-----BEGIN DATA-----\nabc\ndef\n-----END DATA-----" <nil>
+	// "single line" <nil>
+	// "" input ended before "-----END DATA-----", the data may be truncated
+}
+
+func TestPrompter_SetDeadline(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	p := NewPrompterWithReader(r)
+
+	p.SetDeadline(time.Now().Add(50 * time.Millisecond))
+	if _, err := p.ReadString(); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("ReadString() error = %v, want %v", err, ErrTimeout)
+	}
+	p.SetDeadline(time.Now().Add(time.Minute))
+	if _, err := w.WriteString("answer\n"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := p.ReadString(); got != "answer" || err != nil {
+		t.Fatalf("ReadString() = %q, %v, want %q", got, err, "answer")
+	}
+	p.SetDeadline(time.Time{})
+
+	// The input after the prompt, such as the input of the command run by sudo, is not consumed by the Prompter.
+	if _, err := w.WriteString("command input\n"); err != nil {
+		t.Fatal(err)
+	}
+	read := make(chan string, 1)
+	go func() {
+		buf := make([]byte, 64)
+		n, _ := r.Read(buf)
+		read <- string(buf[:n])
+	}()
+	select {
+	case got := <-read:
+		if got != "command input\n" {
+			t.Errorf("input after the prompt = %q, want %q", got, "command input\n")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("input after the prompt was consumed by the Prompter")
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package pam
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/theparanoids/pam-ysshca/audit"
+	"github.com/theparanoids/pam-ysshca/conf"
+	"github.com/theparanoids/pam-ysshca/msg"
+	"github.com/theparanoids/ysshra/sshutils/key"
+	"golang.org/x/crypto/ssh"
+)
+
+// newAudit returns the Audit that records the events to the sinks of the AuditSink directive.
+func newAudit(sinks []conf.AuditSink) audit.Audit {
+	var auditors []audit.Audit
+	for _, sink := range sinks {
+		auditor, err := audit.NewSink(sink.Type, sink.Target)
+		if err != nil {
+			msg.Printlf(msg.WARN, "Failed to create audit sink %s: %v", sink.Type, err)
+			continue
+		}
+		auditors = append(auditors, auditor)
+	}
+	return audit.Multi(auditors...)
+}
+
+// record records the event, see recordEvent. The failure is logged, and doesn't block the authentication.
+func (a *authenticator) record(e *audit.Event) {
+	if err := a.recordEvent(e); err != nil {
+		msg.Printlf(msg.WARN, "Failed to record audit event: %v", err)
+	}
+}
+
+// recordEvent fills the fields of the current authentication request in the event, and records it.
+// The event is recorded by the original effective user of the PAM application,
+// so that the audit logs may be writable only by root.
+func (a *authenticator) recordEvent(e *audit.Event) error {
+	if a.auditor == nil {
+		return nil
+	}
+	e.Time = time.Now()
+	e.Host, _ = os.Hostname()
+	if e.User == "" {
+		e.User = a.user
+	}
+	e.Target = targetUser(a.service, a.cmd)
+	e.Service = a.service
+	e.TTY = a.tty
+	e.Command = string(a.cmd)
+	e.RemoteAddr = a.remoteAddr
+	if e.CAFingerprint != "" {
+		e.CALabel = a.caLabel(e.CAFingerprint)
+	}
+
+	return a.asOrigEUID(func() error {
+		return a.auditor.Record(e)
+	})
+}
+
+// caLabel returns the label of the CA with the fingerprint in TrustedUserCAKeys, which is the comment of the CA key,
+// or the path of the file if the CA key has no comment. It returns an empty string if the CA is not found.
+func (a *authenticator) caLabel(fingerprint string) string {
+	for _, path := range a.config.CAKeys {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		keys, comments, err := key.GetPublicKeysFromBytes(data)
+		if err != nil {
+			continue
+		}
+		for i, caKey := range keys {
+			if ssh.FingerprintSHA256(caKey) != fingerprint {
+				continue
+			}
+			if i < len(comments) && strings.TrimSpace(comments[i]) != "" {
+				return strings.TrimSpace(comments[i])
+			}
+			return path
+		}
+	}
+	return ""
+}
+
+// asOrigEUID runs fn as the original effective user of the PAM application (root for sudo),
+// and switches back to the current effective user afterwards.
+func (a *authenticator) asOrigEUID(fn func() error) error {
+	if euid := os.Geteuid(); euid != a.origEUID {
+		if err := syscall.Setreuid(-1, a.origEUID); err != nil {
+			return fmt.Errorf("failed to switch to euid %d: %v", a.origEUID, err)
+		}
+		defer syscall.Setreuid(-1, euid) //nolint:errcheck
+	}
+	return fn()
+}
@@ -5,7 +5,7 @@ package pam
 
 import (
 	"github.com/theparanoids/pam-ysshca/msg"
-	sshagent "github.com/theparanoids/ysshra/agent/ssh"
+	"github.com/theparanoids/pam-ysshca/sshutils/nonce"
 	"github.com/theparanoids/ysshra/keyid"
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/agent"
@@ -22,9 +22,10 @@ func (a *authenticator) authStaticKey(ag agent.Agent, identities []ssh.PublicKey
 	}
 
 	// Challenge static keys.
+	a.challenged = true
 	for _, key := range userKeys {
 		msg.Printlf(msg.DEBUG, "Start to challenge public key %s", ssh.MarshalAuthorizedKey(key))
-		if err := sshagent.ChallengeSSHAgent(ag, key); err != nil {
+		if err := a.challengeSSHAgent(ag, key); err != nil {
 			msg.Printlf(msg.DEBUG, "Challenge Failed: %v", err)
 			continue
 		}
@@ -44,24 +45,16 @@ func (a *authenticator) authCertificate(ag agent.Agent, identities []ssh.PublicK
 	}
 
 	// Challenge the certificates signed by authorized CAs.
-	for i, userCert := range userCerts {
-		var challenge = sshagent.ChallengeSSHAgent
-		// Decorate ChallengeSSHAgent() by adding a prompt message.
-		for _, prompt := range a.config.Prompters {
-			kid, err := keyid.Unmarshal(userCert.KeyId)
-			if err != nil {
-				msg.Printlf(msg.DEBUG, "KeyID in identity %d is not valid, skip prompter", i, err)
-				continue
-			}
-			if !prompt.RE.MatchString(kid.GetProperty(prompt.KeyIDProperty)) {
-				continue
-			}
+	a.challenged = true
+	for _, userCert := range userCerts {
+		var challenge = a.challengeSSHAgent
+		// Decorate challengeSSHAgent() by adding a prompt message.
+		if message := a.promptMessage(userCert); message != "" {
 			challenge = func(ag agent.Agent, key ssh.PublicKey) error {
-				msg.Print(prompt.Message)
+				msg.Print(message)
 				defer msg.Printf("\n")
-				return sshagent.ChallengeSSHAgent(ag, key)
+				return a.challengeSSHAgent(ag, key)
 			}
-			break
 		}
 		// Challenge the certificate.
 		msg.Printlf(msg.DEBUG, "Start to challenge public key %s", ssh.MarshalAuthorizedKey(userCert))
@@ -73,3 +66,37 @@ func (a *authenticator) authCertificate(ag agent.Agent, identities []ssh.PublicK
 	}
 	return nil
 }
+
+// promptMessage returns the message of the first Prompter that matches the Key ID of the certificate,
+// or an empty string if none matches.
+func (a *authenticator) promptMessage(cert *ssh.Certificate) string {
+	if len(a.config.Prompters) == 0 {
+		return ""
+	}
+	kid, err := keyid.Unmarshal(cert.KeyId)
+	if err != nil {
+		msg.Printlf(msg.DEBUG, "KeyID of the certificate is not valid, skip prompter: %v", err)
+		return ""
+	}
+	for _, prompt := range a.config.Prompters {
+		if prompt.RE.MatchString(kid.GetProperty(prompt.KeyIDProperty)) {
+			return prompt.Message
+		}
+	}
+	return ""
+}
+
+// approve completes the authentication by the certificate after its challenge succeeds.
+// It records the use of a nonce certificate, and handles the access by a firefighter certificate.
+func (a *authenticator) approve(cert *ssh.Certificate) error {
+	if a.nonceDB != nil && nonce.IsNonce(cert) {
+		// The database is owned by root, so it is written as the original effective user.
+		if err := a.asOrigEUID(func() error { return a.nonceDB.Use(cert) }); err != nil {
+			return err
+		}
+	}
+	if isFirefighter(cert) {
+		return a.breakGlass(cert)
+	}
+	return nil
+}
@@ -6,13 +6,18 @@ package pam
 import (
 	"crypto/rand"
 	"crypto/rsa"
+	"errors"
 	"os"
+	"path/filepath"
 	"reflect"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/theparanoids/pam-ysshca/conf"
 	"github.com/theparanoids/pam-ysshca/msg"
+	"github.com/theparanoids/pam-ysshca/sshutils/nonce"
+	"github.com/theparanoids/ysshra/keyid"
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/agent"
 )
@@ -164,6 +169,55 @@ func Test_authenticator_authCertificate(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "cert without required extension should fail",
+			getTestData: func(t *testing.T) (string, *ssh.Certificate, agent.Agent, conf.Config) {
+				sshAgent := agent.NewKeyring()
+				caSignkey, err := rsa.GenerateKey(rand.Reader, 2048)
+				if err != nil {
+					t.Fatal(t)
+				}
+				caSigner, err := ssh.NewSignerFromKey(caSignkey)
+				if err != nil {
+					t.Fatal(t)
+				}
+				private, err := rsa.GenerateKey(rand.Reader, 2048)
+				if err != nil {
+					t.Fatal(t)
+				}
+				public, err := ssh.NewPublicKey(private.Public())
+				if err != nil {
+					t.Fatal(t)
+				}
+				cert := &ssh.Certificate{
+					Key:             public,
+					ValidPrincipals: []string{"valid_user"},
+					ValidAfter:      uint64(time.Now().Unix() - 3600),
+					ValidBefore:     uint64(time.Now().Unix() + 3600),
+					Permissions: ssh.Permissions{
+						Extensions: map[string]string{"permit-pty": ""},
+					},
+				}
+				if err := cert.SignCert(rand.Reader, caSigner); err != nil {
+					t.Fatal(err)
+				}
+				if err := sshAgent.Add(agent.AddedKey{PrivateKey: private, Certificate: cert}); err != nil {
+					t.Fatal(err)
+				}
+				caKeyFile, err := os.CreateTemp(t.TempDir(), "test-ca-keys-file")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := caKeyFile.Write(ssh.MarshalAuthorizedKey(cert.SignatureKey)); err != nil {
+					t.Fatal(err)
+				}
+				return "valid_user", nil, sshAgent, conf.Config{
+					AllowCertificate:   true,
+					CAKeys:             []string{caKeyFile.Name()},
+					RequiredExtensions: []conf.RequiredExtension{{Name: "permit-sudo@ysshca"}},
+				}
+			},
+		},
 		{
 			name: "cert signed by invalid CA should fail",
 			getTestData: func(t *testing.T) (string, *ssh.Certificate, agent.Agent, conf.Config) {
@@ -244,3 +298,81 @@ func Test_authenticator_authCertificate(t *testing.T) {
 		})
 	}
 }
+
+func Test_authenticator_approve_noNonceDB(t *testing.T) {
+	t.Parallel()
+	kid, err := (&keyid.KeyID{Principals: []string{"alice"}, IsNonce: true}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &authenticator{config: &conf.Config{}}
+	if err := a.approve(&ssh.Certificate{KeyId: kid}); err != nil {
+		t.Errorf("approve() of a nonce certificate without the replay database error = %v", err)
+	}
+}
+
+// Test_authenticator_approve_nonceDB checks the root-owned replay database while the euid is switched to the user,
+// as in Authenticate.
+func Test_authenticator_approve_nonceDB(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("switching euid requires root")
+	}
+	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := ssh.NewSignerFromKey(caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid, err := (&keyid.KeyID{Principals: []string{"alice"}, TransID: "trans-1", IsNonce: true}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := &ssh.Certificate{
+		Key:             ca.PublicKey(),
+		Serial:          1,
+		CertType:        ssh.UserCert,
+		KeyId:           kid,
+		ValidPrincipals: []string{"alice"},
+		ValidBefore:     uint64(time.Now().Add(time.Hour).Unix()),
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "pam_sshca", "nonce.db")
+	a := &authenticator{
+		config:   &conf.Config{},
+		nonceDB:  nonce.NewDB(path),
+		origEUID: os.Geteuid(),
+	}
+
+	const nobody = 65534
+	if err := syscall.Setreuid(-1, nobody); err != nil {
+		t.Fatal(err)
+	}
+	err1 := a.approve(cert)
+	err2 := a.approve(cert)
+	euid := os.Geteuid()
+	if err := syscall.Setreuid(-1, a.origEUID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err1 != nil {
+		t.Errorf("approve() of the unused nonce certificate error = %v", err1)
+	}
+	if !errors.Is(err2, nonce.ErrReplayed) {
+		t.Errorf("approve() of the replayed nonce certificate error = %v, want %v", err2, nonce.ErrReplayed)
+	}
+	if euid != nobody {
+		t.Errorf("euid after approve() = %d, want %d", euid, nobody)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat := info.Sys().(*syscall.Stat_t); stat.Uid != 0 {
+		t.Errorf("replay database is owned by uid %d, want 0", stat.Uid)
+	}
+}
@@ -6,14 +6,23 @@ package pam
 import (
 	"bytes"
 	"crypto/sha256"
+	"fmt"
 	"strings"
+	"time"
 
+	"github.com/theparanoids/pam-ysshca/audit"
+	"github.com/theparanoids/pam-ysshca/conf"
 	"github.com/theparanoids/pam-ysshca/msg"
+	"github.com/theparanoids/pam-ysshca/sshutils/nonce"
+	"github.com/theparanoids/ysshra/keyid"
 	"github.com/theparanoids/ysshra/sshutils/key"
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/agent"
 )
 
+// timeNow returns the current time. It is a variable for testing.
+var timeNow = time.Now
+
 type hashcode [sha256.Size]byte
 
 func hash(key ssh.PublicKey) hashcode {
@@ -70,22 +79,66 @@ func getIdentitiesFromSSHAgent(sshagent agent.Agent) (keys []ssh.PublicKey, err
 }
 
 // matchValidPrincipal uses hash table to speed up the process to match a valid principal.
-func matchValidPrincipal(cert *ssh.Certificate, principals map[string]bool) bool {
-	for _, principal := range cert.ValidPrincipals {
-		if principals[principal] {
+// A principal is valid if the options of any of its authorized entries permit the current request.
+// The principals of the certificate that are not authorized are mapped by the PrincipalMap rules,
+// and the mapped principals are matched again.
+// It returns the matched principal of the certificate, and the PrincipalMap rule applied to it (nil if none).
+func (a *authenticator) matchValidPrincipal(cert *ssh.Certificate, principals map[string][]conf.PrincipalOptions) (string, *conf.PrincipalMap, bool) {
+	now := timeNow()
+	permit := func(principal string) bool {
+		for _, opts := range principals[principal] {
+			if err := opts.Permit(a.remoteAddr, string(a.cmd), now); err != nil {
+				msg.Printlf(msg.DEBUG, "Principal %s is not permitted: %v", principal, err)
+				continue
+			}
 			return true
 		}
+		return false
 	}
-	return false
+
+	for _, principal := range cert.ValidPrincipals {
+		if permit(principal) {
+			return principal, nil, true
+		}
+		for i, rule := range a.config.PrincipalMaps {
+			mapped, ok := rule.Map(principal)
+			if !ok {
+				continue
+			}
+			msg.Printlf(msg.DEBUG, "Principal %s is mapped to %s by rule (%s)", principal, mapped, rule)
+			if permit(mapped) {
+				return principal, &a.config.PrincipalMaps[i], true
+			}
+		}
+	}
+	return "", nil, false
 }
 
 // getValidStaticKeys returns all the valid static keys for the given identities.
 // It traverses all the keys in the static key files, and returns the ones that match the identities.
 func (a *authenticator) getValidStaticKeys(identities []ssh.PublicKey) []ssh.PublicKey {
+	// If a static key file fails to load, ignore and continue with the other sources of keys.
 	var authorizedKeyMap = newPublicKeyMap()
-	if err := authorizedKeyMap.load(a.config.StaticKeys); err != nil {
-		msg.Printlf(msg.DEBUG, "Failed to load public keys: %v", err)
-		return nil
+	for _, path := range a.config.StaticKeys {
+		if err := authorizedKeyMap.load([]string{path}); err != nil {
+			msg.Printlf(msg.DEBUG, "Failed to load public keys: %v", err)
+		}
+	}
+	// Merge the keys printed by AuthorizedKeysCommand.
+	// If the command fails, ignore and continue with the keys from the static key files.
+	// The command runs as the original effective user, so that the user cannot tamper with it.
+	if len(a.config.AuthorizedKeysCommand) != 0 {
+		var keys []ssh.PublicKey
+		err := a.asOrigEUID(func() (err error) {
+			keys, err = runKeysCommand(a.config.AuthorizedKeysCommand, a.origEUID, keysCommandTimeout, keysCommandMaxOutput)
+			return err
+		})
+		if err != nil {
+			msg.Printlf(msg.WARN, "Failed to run AuthorizedKeysCommand: %v", err)
+		} else {
+			msg.Printlf(msg.DEBUG, "Found %d public keys from AuthorizedKeysCommand.", len(keys))
+			authorizedKeyMap.append(keys)
+		}
 	}
 	var keys = make([]ssh.PublicKey, len(identities))[:0]
 	for _, identity := range identities {
@@ -130,40 +183,100 @@ func (a *authenticator) getValidCertificates(identities []ssh.PublicKey, usernam
 			msg.Printlf(msg.DEBUG, "Identity %d is not a certificate, ignore.", index)
 			continue
 		}
-
-		// Check the signing CA of the certificate.
-		if !CAKeyMap.contains(cert.SignatureKey) {
-			msg.Printlf(msg.DEBUG, "Identity %d is signed by untrusted CA.", index)
+		if err := a.validateCertificate(cert, username, principals, CAKeyMap); err != nil {
+			msg.Printlf(msg.DEBUG, "Identity %d is invalid: %v.", index, err)
 			continue
 		}
+		certs = append(certs, cert)
+	}
 
-		// Check the valid principals efficiently using hash map.
-		msg.Printlf(msg.DEBUG, "Current acceptable principals: %v", principals)
-		msg.Printlf(msg.DEBUG, "Certificate principals: %v", cert.ValidPrincipals)
-		if !matchValidPrincipal(cert, principals) {
-			msg.Printlf(msg.DEBUG, "Identity %d does not have a valid principals, authorized prins: %v, prins from cert: %s",
-				index, cert.ValidPrincipals, principals)
-			continue
-		}
+	return certs
+}
 
-		// As the authorized principals have been matched to the certificate,
-		// skip the inefficient valid principals check in ssh.CertChecker.
-		var principal string
-		if len(cert.ValidPrincipals) > 0 {
-			principal = cert.ValidPrincipals[0]
-		}
+// validateCertificate is the validation pipeline of certificates, shared by the ssh-agent path and
+// the fallback authentication methods (see Validator).
+// principals are the authorized principals of username, and caKeys are the trusted CA keys.
+func (a *authenticator) validateCertificate(cert *ssh.Certificate, username string, principals map[string][]conf.PrincipalOptions, caKeys publicKeyMap) error {
+	// Check the signing CA of the certificate.
+	if !caKeys.contains(cert.SignatureKey) {
+		return fmt.Errorf("signed by untrusted CA")
+	}
+
+	// Check the valid principals efficiently using hash map.
+	msg.Printlf(msg.DEBUG, "Current acceptable principals: %v", principals)
+	msg.Printlf(msg.DEBUG, "Certificate principals: %v", cert.ValidPrincipals)
+	matched, rule, ok := a.matchValidPrincipal(cert, principals)
+	if !ok {
+		return fmt.Errorf("no valid principals, prins from cert: %v", cert.ValidPrincipals)
+	}
+
+	// As the authorized principals have been matched to the certificate,
+	// skip the inefficient valid principals check in ssh.CertChecker.
+	var principal string
+	if len(cert.ValidPrincipals) > 0 {
+		principal = cert.ValidPrincipals[0]
+	}
 
-		// Check the critical options, revocation, timestamp and
-		// the signature of the certificate using ssh.CertChecker.
-		checker := ssh.CertChecker{
-			SupportedCriticalOptions: a.config.SupportedCriticalOptions,
+	// Check the critical options, revocation, timestamp (with tolerance of ClockSkew) and
+	// the signature of the certificate using ssh.CertChecker.
+	now := timeNow()
+	checker := ssh.CertChecker{
+		SupportedCriticalOptions: append([]string{conf.HostsOption}, a.config.SupportedCriticalOptions...),
+		Clock:                    conf.SkewedClock(cert, now, a.config.ClockSkew),
+	}
+	if err := checker.CheckCert(principal, cert); err != nil {
+		return err
+	}
+	// Check the lifetime and the remaining validity of the certificate.
+	if err := conf.CheckValidity(cert, now, a.config.MaxCertLifetime, a.config.MinRemainingValidity); err != nil {
+		return err
+	}
+	// Check the extensions required for the current PAM service.
+	if err := conf.CheckRequiredExtensions(cert, a.config.RequiredExtensions); err != nil {
+		return err
+	}
+	// Check whether the certificate is allowed at the current time.
+	if err := conf.CheckAllowedTimes(cert, a.config.AllowedTimes, now); err != nil {
+		return err
+	}
+	// Check whether the certificate is bound to other hosts.
+	if err := conf.CheckHostBinding(cert, a.config.HostTags); err != nil {
+		return err
+	}
+	// Check whether firefighter certificates are allowed.
+	if !a.config.AllowFirefighter && isFirefighter(cert) {
+		return fmt.Errorf("firefighter certificates are not allowed")
+	}
+
+	// Check the Require and Deny rules on Key ID.
+	if len(a.config.KeyIDRules) != 0 {
+		kid, err := keyid.Unmarshal(cert.KeyId)
+		if err != nil {
+			return fmt.Errorf("invalid KeyID: %v", err)
 		}
-		if err := checker.CheckCert(principal, cert); err != nil {
-			msg.Printlf(msg.DEBUG, "Identity %d is invalid: %v.", index, err)
-			continue
+		if err := conf.CheckKeyIDRules(kid, a.config.KeyIDRules); err != nil {
+			return err
 		}
-		certs = append(certs, cert)
 	}
-
-	return certs
+	// Check whether the nonce certificate has been used.
+	// The database is owned by root, so it is read as the original effective user.
+	if a.nonceDB != nil && nonce.IsNonce(cert) {
+		var used bool
+		err := a.asOrigEUID(func() (err error) {
+			used, err = a.nonceDB.Used(cert)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to check nonce certificate: %v", err)
+		}
+		if used {
+			return nonce.ErrReplayed
+		}
+	}
+	if rule != nil {
+		e := &audit.Event{Type: audit.TypePrincipalMap, User: username, Details: map[string]string{"principal": matched, "rule": rule.String()}}
+		e.SetKey(cert)
+		a.record(e)
+	}
+	return nil
 }
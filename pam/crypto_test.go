@@ -9,6 +9,8 @@ import (
 	"crypto/rsa"
 	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strconv"
 	"testing"
 	"time"
@@ -196,6 +198,35 @@ func TestGetInvalidStaticKeys(t *testing.T) {
 
 }
 
+func TestGetValidStaticKeys_missingFile(t *testing.T) {
+	t.Parallel()
+	sshagent := agent.NewKeyring()
+	fakeKeys := testKeys(t)[:2]
+	addKeys(t, sshagent, fakeKeys)
+	identities, err := getIdentitiesFromSSHAgent(sshagent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The key of the first identity is printed by AuthorizedKeysCommand.
+	keysFile := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(keysFile, ssh.MarshalAuthorizedKey(identities[0]), 0644); err != nil {
+		t.Fatal(err)
+	}
+	a := &authenticator{
+		config: &conf.Config{
+			AllowStaticKeys:       true,
+			StaticKeys:            []string{filepath.Join(t.TempDir(), "missing")},
+			AuthorizedKeysCommand: []string{"/bin/cat", keysFile},
+		},
+	}
+
+	pubKeys := a.getValidStaticKeys(identities)
+	if len(pubKeys) != 1 || !bytes.Equal(pubKeys[0].Marshal(), identities[0].Marshal()) {
+		t.Errorf("getValidStaticKeys() = %v, want the key from AuthorizedKeysCommand", pubKeys)
+	}
+}
+
 func TestGetValidCertificates(t *testing.T) {
 	t.Parallel()
 	// Generate fake certificates and add them to ssh-agent.
@@ -243,3 +274,72 @@ func TestGetValidCertificates(t *testing.T) {
 		}
 	}
 }
+
+func Test_authenticator_matchValidPrincipal(t *testing.T) {
+	t.Parallel()
+	principalMap := func(re, replacement string) conf.PrincipalMap {
+		return conf.PrincipalMap{RE: regexp.MustCompile("^(?:" + re + ")$"), Replacement: replacement}
+	}
+	tests := []struct {
+		name          string
+		certPrins     []string
+		principals    map[string][]conf.PrincipalOptions
+		principalMaps []conf.PrincipalMap
+		remoteAddr    string
+		wantPrincipal string
+		wantRule      bool
+		wantOK        bool
+	}{
+		{
+			name:          "direct match",
+			certPrins:     []string{"other", "alice"},
+			principals:    map[string][]conf.PrincipalOptions{"alice": {{}}},
+			wantPrincipal: "alice",
+			wantOK:        true,
+		},
+		{
+			name:          "mapped match",
+			certPrins:     []string{"alice@CORP.EXAMPLE"},
+			principals:    map[string][]conf.PrincipalOptions{"alice": {{}}},
+			principalMaps: []conf.PrincipalMap{principalMap(`(.*)@CORP\.EXAMPLE`, "$1")},
+			wantPrincipal: "alice@CORP.EXAMPLE",
+			wantRule:      true,
+			wantOK:        true,
+		},
+		{
+			name:          "mapped principal is not authorized",
+			certPrins:     []string{"bob@CORP.EXAMPLE"},
+			principals:    map[string][]conf.PrincipalOptions{"alice": {{}}},
+			principalMaps: []conf.PrincipalMap{principalMap(`(.*)@CORP\.EXAMPLE`, "$1")},
+		},
+		{
+			name:       "principal is not permitted by its options",
+			certPrins:  []string{"alice"},
+			principals: map[string][]conf.PrincipalOptions{"alice": {{ExpiryTime: time.Unix(1, 0)}}},
+		},
+		{
+			name:          "from permits PAM_RHOST",
+			certPrins:     []string{"alice"},
+			principals:    map[string][]conf.PrincipalOptions{"alice": {{From: []string{"10.0.0.0/8"}}}},
+			remoteAddr:    "10.1.2.3",
+			wantPrincipal: "alice",
+			wantOK:        true,
+		},
+		{
+			// Without PAM_RHOST, e.g. sudo in a local session, from= fails closed.
+			name:       "from denies without PAM_RHOST",
+			certPrins:  []string{"alice"},
+			principals: map[string][]conf.PrincipalOptions{"alice": {{From: []string{"*"}}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &authenticator{config: &conf.Config{PrincipalMaps: tt.principalMaps}, remoteAddr: tt.remoteAddr}
+			cert := &ssh.Certificate{ValidPrincipals: tt.certPrins}
+			principal, rule, ok := a.matchValidPrincipal(cert, tt.principals)
+			if ok != tt.wantOK || principal != tt.wantPrincipal || (rule != nil) != tt.wantRule {
+				t.Errorf("matchValidPrincipal() = (%q, %v, %v), want (%q, rule %v, %v)", principal, rule, ok, tt.wantPrincipal, tt.wantRule, tt.wantOK)
+			}
+		})
+	}
+}
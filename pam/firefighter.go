@@ -0,0 +1,135 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package pam
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/theparanoids/pam-ysshca/audit"
+	"github.com/theparanoids/pam-ysshca/msg"
+	"github.com/theparanoids/ysshra/keyid"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	justificationPrompt = "You are using a firefighter (break-glass) certificate. This access is audited and reported.\nPlease enter the justification:"
+	// notifyTimeout is the maximum time to notify each notifier of FirefighterNotify.
+	notifyTimeout = 5 * time.Second
+)
+
+// notifyClient is the HTTP client of the webhooks of FirefighterNotify.
+// It ignores the proxy settings in the environment, which may be controlled by the user.
+var notifyClient = &http.Client{
+	Transport: &http.Transport{Proxy: nil},
+	Timeout:   notifyTimeout,
+}
+
+// firefighterEvent is the record of an access by a firefighter certificate that is sent to the notifiers.
+type firefighterEvent struct {
+	Time          time.Time `json:"time"`
+	Host          string    `json:"host"`
+	User          string    `json:"user"`
+	Service       string    `json:"service"`
+	RemoteAddr    string    `json:"remoteAddr"`
+	Command       string    `json:"command"`
+	KeyID         string    `json:"keyID"`
+	Justification string    `json:"justification"`
+}
+
+// isFirefighter returns true if the Key ID of the certificate marks it as a firefighter certificate.
+func isFirefighter(cert *ssh.Certificate) bool {
+	kid, err := keyid.Unmarshal(cert.KeyId)
+	return err == nil && kid.IsFirefighter
+}
+
+// breakGlass handles the access by a firefighter certificate.
+// It requires a justification from the user, writes a high-severity audit record,
+// and notifies the notifiers of FirefighterNotify.
+// A failed notification blocks the access only if FirefighterNotifyRequired is set.
+func (a *authenticator) breakGlass(cert *ssh.Certificate) error {
+	a.prompter.Prompt(justificationPrompt)
+	justification, err := a.prompter.ReadString()
+	if err != nil {
+		return err
+	}
+	if justification == "" {
+		return errors.New("justification is required for firefighter certificates")
+	}
+
+	e := &audit.Event{Type: audit.TypeFirefighter, Details: map[string]string{"justification": justification}}
+	e.SetKey(cert)
+	a.record(e)
+
+	host, _ := os.Hostname()
+	event, err := json.Marshal(firefighterEvent{
+		Time:          time.Now(),
+		Host:          host,
+		User:          a.user,
+		Service:       a.service,
+		RemoteAddr:    a.remoteAddr,
+		Command:       string(a.cmd),
+		KeyID:         cert.KeyId,
+		Justification: justification,
+	})
+	if err != nil {
+		return err
+	}
+	var notifyErr error
+	for _, notifier := range a.config.FirefighterNotify {
+		// The programs run as the original effective user, so that the user cannot tamper with them.
+		err := a.asOrigEUID(func() error { return notify(notifier, event, a.origEUID, notifyTimeout) })
+		if err != nil {
+			msg.Printlf(msg.WARN, "Failed to notify %s: %v", notifier, err)
+			a.record(&audit.Event{Type: audit.TypeNotifyFailed, Error: err.Error(), Details: map[string]string{"notifier": notifier}})
+			notifyErr = fmt.Errorf("failed to notify %s: %v", notifier, err)
+		}
+	}
+	if a.config.FirefighterNotifyRequired {
+		return notifyErr
+	}
+	return nil
+}
+
+// notify sends the event to the notifier.
+// The event is posted to a webhook URL, or written to the standard input of a program.
+// The program runs with a minimal environment (commandEnv), and with all its user IDs set to uid.
+func notify(notifier string, event []byte, uid int, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if strings.HasPrefix(notifier, "https://") || strings.HasPrefix(notifier, "http://") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, notifier, bytes.NewReader(event))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := notifyClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		return nil
+	}
+
+	cmd := exec.CommandContext(ctx, notifier)
+	cmd.Env = commandEnv
+	cmd.Stdin = bytes.NewReader(event)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Credential: &syscall.Credential{Uid: uint32(uid), Gid: uint32(os.Getegid()), NoSetGroups: true},
+	}
+	return cmd.Run()
+}
@@ -0,0 +1,163 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package pam
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/theparanoids/pam-ysshca/conf"
+	"github.com/theparanoids/pam-ysshca/msg"
+	"github.com/theparanoids/ysshra/keyid"
+	"golang.org/x/crypto/ssh"
+)
+
+func testFirefighterCert(t *testing.T, firefighter bool) *ssh.Certificate {
+	kid := &keyid.KeyID{
+		Principals:    []string{"user"},
+		Version:       keyid.DefaultVersion,
+		IsFirefighter: firefighter,
+	}
+	keyID, err := kid.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &ssh.Certificate{KeyId: keyID}
+}
+
+func Test_isFirefighter(t *testing.T) {
+	t.Parallel()
+	if !isFirefighter(testFirefighterCert(t, true)) {
+		t.Errorf("isFirefighter() = false, want true")
+	}
+	if isFirefighter(testFirefighterCert(t, false)) {
+		t.Errorf("isFirefighter() = true, want false")
+	}
+	if isFirefighter(&ssh.Certificate{KeyId: "invalid"}) {
+		t.Errorf("isFirefighter() = true for invalid key id, want false")
+	}
+}
+
+func Test_authenticator_breakGlass(t *testing.T) {
+	t.Parallel()
+	events := make(chan firefighterEvent, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event firefighterEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		events <- event
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name              string
+		input             string
+		notifyRequired    bool
+		wantErr           bool
+		wantJustification string
+	}{
+		{
+			name:              "happy path",
+			input:             "incident 1234\n",
+			wantJustification: "incident 1234",
+		},
+		{
+			name:    "empty justification",
+			input:   "\n",
+			wantErr: true,
+		},
+		{
+			name:    "no input",
+			wantErr: true,
+		},
+		{
+			name:           "failed notifier is required",
+			input:          "incident 1234\n",
+			notifyRequired: true,
+			wantErr:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &authenticator{
+				user: "user",
+				cmd:  []byte("sudo ls"),
+				config: &conf.Config{
+					FirefighterNotify:         []string{server.URL, "/non-existing-notifier"},
+					FirefighterNotifyRequired: tt.notifyRequired,
+				},
+				prompter: msg.NewPrompterWithReader(strings.NewReader(tt.input)),
+				origEUID: os.Geteuid(),
+			}
+			err := a.breakGlass(testFirefighterCert(t, true))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("breakGlass() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			select {
+			case event := <-events:
+				if event.Justification != tt.wantJustification || event.User != "user" || event.Command != "sudo ls" {
+					t.Errorf("breakGlass() sent event %+v", event)
+				}
+			case <-time.After(time.Second):
+				t.Errorf("breakGlass() didn't notify the webhook")
+			}
+		})
+	}
+}
+
+func Test_notify(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	out := filepath.Join(dir, "event")
+	notifier := filepath.Join(dir, "notifier")
+	// The program records its environment, which must not include the environment of the PAM application.
+	script := "#!/bin/sh\ncat > " + out + "\nenv > " + out + ".env\n"
+	if err := os.WriteFile(notifier, []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	uid := os.Geteuid()
+	if err := notify(notifier, []byte(`{"user":"user"}`), uid, 5*time.Second); err != nil {
+		t.Fatalf("notify() unexpected error: %v", err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != `{"user":"user"}` {
+		t.Errorf("notify() wrote %q to the program", got)
+	}
+	env, err := os.ReadFile(out + ".env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(env), "HOME=") || !strings.Contains(string(env), commandEnv[0]) {
+		t.Errorf("notify() ran the program with environment %q, want %q", env, commandEnv)
+	}
+	if err := notify(server.URL, []byte("{}"), uid, time.Second); err == nil {
+		t.Errorf("notify() expected error for failed webhook")
+	}
+	if err := notify(filepath.Join(dir, "non-existing"), []byte("{}"), uid, time.Second); err == nil {
+		t.Errorf("notify() expected error for non-existing program")
+	}
+	if notifyClient.Transport.(*http.Transport).Proxy != nil {
+		t.Errorf("notifyClient uses the proxy settings in the environment")
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package pam
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/theparanoids/ysshra/sshutils/key"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// keysCommandTimeout is the maximum time that AuthorizedKeysCommand may run.
+	keysCommandTimeout = 5 * time.Second
+	// keysCommandMaxOutput is the maximum number of bytes read from the output of AuthorizedKeysCommand.
+	keysCommandMaxOutput = 1 << 20
+	// keysCommandWaitDelay is the time to wait for the output of AuthorizedKeysCommand to be closed
+	// after the command exits or is killed, e.g. if a background process of the command holds the output.
+	keysCommandWaitDelay = time.Second
+)
+
+// commandEnv is the environment of AuthorizedKeysCommand and the programs of FirefighterNotify.
+// The environment of the PAM application may be controlled by the user, so it is not passed to the commands.
+var commandEnv = []string{"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"}
+
+// limitedBuffer is a buffer that rejects the writes beyond max bytes, and calls overflow once it does.
+type limitedBuffer struct {
+	bytes.Buffer
+	max      int64
+	overflow func()
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if int64(b.Len()+len(p)) > b.max {
+		if !b.exceeded {
+			b.exceeded = true
+			b.overflow()
+		}
+		return 0, errors.New("output too large")
+	}
+	return b.Buffer.Write(p)
+}
+
+// runKeysCommand invokes the command and parses its output in OpenSSH AUTHORIZED_KEYS format.
+// The command runs with a minimal environment (commandEnv), and is killed if it runs longer
+// than timeout or prints more than maxOutput bytes.
+// The real, effective and saved user IDs of the command are all set to uid, so that the user
+// cannot trace or signal it. Setting them to another user requires the effective user ID to be root.
+func runKeysCommand(cmdline []string, uid int, timeout time.Duration, maxOutput int64) ([]ssh.PublicKey, error) {
+	if len(cmdline) == 0 {
+		return nil, errors.New("empty command")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	out := &limitedBuffer{max: maxOutput, overflow: cancel}
+	cmd := exec.CommandContext(ctx, cmdline[0], cmdline[1:]...)
+	cmd.Env = commandEnv
+	cmd.Stdout = out
+	cmd.WaitDelay = keysCommandWaitDelay
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Credential: &syscall.Credential{Uid: uint32(uid), Gid: uint32(os.Getegid()), NoSetGroups: true},
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("cannot execute %s: %v", cmdline[0], err)
+	}
+	if err := cmd.Wait(); err != nil {
+		switch {
+		case out.exceeded:
+			return nil, fmt.Errorf("output of %s exceeds %d bytes", cmdline[0], maxOutput)
+		case ctx.Err() != nil:
+			return nil, fmt.Errorf("%s timed out after %v", cmdline[0], timeout)
+		}
+		return nil, fmt.Errorf("%s failed: %v", cmdline[0], err)
+	}
+
+	keys, _, err := key.GetPublicKeysFromBytes(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse output of %s: %v", cmdline[0], err)
+	}
+	return keys, nil
+}
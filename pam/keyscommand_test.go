@@ -0,0 +1,172 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package pam
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/theparanoids/pam-ysshca/conf"
+	"golang.org/x/crypto/ssh"
+)
+
+// keysCommandSelf returns the command line to run the test binary as AuthorizedKeysCommand.
+// The mode and the output are passed in the arguments after "--", because the command doesn't inherit the environment.
+func keysCommandSelf(mode string, output string) []string {
+	return []string{os.Args[0], "-test.run=^Test_runKeysCommand$", "--", mode, output}
+}
+
+func Test_runKeysCommand(t *testing.T) {
+	self := keysCommandSelf
+	switch flag.Arg(0) {
+	case "":
+	case "print":
+		os.Stdout.WriteString(flag.Arg(1))
+		os.Exit(0)
+	case "flood":
+		os.Stdout.WriteString(strings.Repeat("x", 4096))
+		os.Exit(0)
+	case "sleep":
+		time.Sleep(10 * time.Second)
+		os.Exit(0)
+	case "fail":
+		os.Exit(1)
+	case "env":
+		if os.Getenv("KEYS_CMD_SECRET") != "" {
+			os.Exit(1)
+		}
+		os.Stdout.WriteString(flag.Arg(1))
+		os.Exit(0)
+	case "root":
+		if os.Getuid() != 0 || os.Geteuid() != 0 {
+			os.Exit(1)
+		}
+		os.Stdout.WriteString(flag.Arg(1))
+		os.Exit(0)
+	case "background":
+		// Leave a process holding the output behind.
+		cmd := exec.Command(os.Args[0], self("sleep", "")[1:]...)
+		cmd.Stdout = os.Stdout
+		if err := cmd.Start(); err != nil {
+			os.Exit(1)
+		}
+		os.Stdout.WriteString(flag.Arg(1))
+		os.Exit(0)
+	}
+
+	pubKeys := testPubKeys(t)[:2]
+	var out bytes.Buffer
+	for _, k := range pubKeys {
+		out.Write(ssh.MarshalAuthorizedKey(k))
+	}
+	t.Setenv("KEYS_CMD_SECRET", "secret")
+
+	tests := []struct {
+		name      string
+		cmdline   []string
+		timeout   time.Duration
+		wantCount int
+		wantErr   bool
+	}{
+		{
+			name:      "happy path",
+			cmdline:   self("print", out.String()),
+			timeout:   5 * time.Second,
+			wantCount: len(pubKeys),
+		},
+		{
+			name:    "output exceeds the cap",
+			cmdline: self("flood", ""),
+			timeout: 5 * time.Second,
+			wantErr: true,
+		},
+		{
+			name:    "command times out",
+			cmdline: self("sleep", ""),
+			timeout: 100 * time.Millisecond,
+			wantErr: true,
+		},
+		{
+			name:    "command fails",
+			cmdline: self("fail", ""),
+			timeout: 5 * time.Second,
+			wantErr: true,
+		},
+		{
+			name:      "environment is not inherited",
+			cmdline:   self("env", out.String()),
+			timeout:   5 * time.Second,
+			wantCount: len(pubKeys),
+		},
+		{
+			name:    "background process holds the output",
+			cmdline: self("background", out.String()),
+			timeout: 5 * time.Second,
+			wantErr: true,
+		},
+		{
+			name:    "command not found",
+			cmdline: []string{"/non-existing-command"},
+			timeout: 5 * time.Second,
+			wantErr: true,
+		},
+		{
+			name:    "empty command",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := runKeysCommand(tt.cmdline, os.Geteuid(), tt.timeout, 1024)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("runKeysCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(keys) != tt.wantCount {
+				t.Errorf("runKeysCommand() got %d keys, want %d", len(keys), tt.wantCount)
+			}
+		})
+	}
+}
+
+// Test_authenticator_getValidStaticKeys_keysCommandUID checks that AuthorizedKeysCommand runs with
+// all the user IDs of root while the euid is switched to the user, as in Authenticate.
+func Test_authenticator_getValidStaticKeys_keysCommandUID(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("switching euid requires root")
+	}
+	pubKey := testPubKeys(t)[0]
+	a := &authenticator{
+		config: &conf.Config{
+			AllowStaticKeys:       true,
+			AuthorizedKeysCommand: keysCommandSelf("root", string(ssh.MarshalAuthorizedKey(pubKey))),
+		},
+		origEUID: os.Geteuid(),
+	}
+
+	// Switch to ruid=euid=nobody with the saved uid root, as sudo run by nobody.
+	const nobody = 65534
+	if err := syscall.Setreuid(nobody, -1); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Setreuid(-1, nobody); err != nil {
+		t.Fatal(err)
+	}
+	keys := a.getValidStaticKeys([]ssh.PublicKey{pubKey})
+	if err := syscall.Setreuid(-1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Setreuid(0, -1); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(keys) != 1 {
+		t.Errorf("getValidStaticKeys() = %v, want the key printed by the command running as root", keys)
+	}
+}
@@ -35,6 +35,19 @@ const char *GetCurrentUserName(pam_handle_t *pamh) {
 	return username;
 }
 
+// GetItem returns the string PAM item of the given type, or NULL if it is not set.
+// It is exported to Go language part.
+const char *GetItem(pam_handle_t *pamh, int item_type) {
+	if (pamh == NULL)
+		return NULL;
+
+	const char *item = NULL;
+	int err = pam_get_item(pamh, item_type, (const void **)&item);
+	if (err != PAM_SUCCESS)
+		return NULL;
+	return item;
+}
+
 struct passwd *_getpwnam(pam_handle_t *pamh) {
 	const char *username = GetCurrentUserName(pamh);
 	if (username == NULL)
@@ -18,21 +18,23 @@ package pam
 // uid_t GetCurrentUserUID(pam_handle_t *pamh);
 // const char *GetCurrentUserName(pam_handle_t *pamh);
 // const char *GetCurrentUserHome(pam_handle_t *pamh);
+// const char *GetItem(pam_handle_t *pamh, int item_type);
 //
 import "C"
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
-	"log/syslog"
 	"net"
 	"os"
 	"syscall"
 
+	"github.com/theparanoids/pam-ysshca/audit"
 	"github.com/theparanoids/pam-ysshca/conf"
 	"github.com/theparanoids/pam-ysshca/msg"
+	"github.com/theparanoids/pam-ysshca/sshutils/nonce"
+	"github.com/theparanoids/pam-ysshca/sshutils/receipt"
 	sshagent "github.com/theparanoids/ysshra/agent/ssh"
-	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/agent"
 )
 
@@ -48,53 +50,67 @@ type authenticator struct {
 	// user is the name of current user.
 	user string
 	// home is the home path of current user.
-	home      string
-	config    *conf.Config
-	sysLogger *syslog.Writer
+	home string
+	// service is the PAM service name, such as "sudo".
+	service string
+	// remoteAddr is the address of the remote host that current user connects from, i.e. PAM_RHOST.
+	// It is empty if the PAM application doesn't set PAM_RHOST. The environment variables such as SSH_CONNECTION
+	// are not trusted, because they are controlled by the user.
+	remoteAddr string
+	// tty is the terminal of the PAM application.
+	tty string
+	// cmd is the command line of the PAM application.
+	cmd    []byte
+	config *conf.Config
+	// auditor records the audit events to the sinks of the AuditSink directive.
+	auditor audit.Audit
+	// challenged specifies whether any valid identity in the ssh-agent has been challenged.
+	challenged bool
+	// nonceDB is the replay database that enforces the single use of nonce certificates.
+	nonceDB  *nonce.DB
+	prompter *msg.Prompter
+	// receipt is the signed challenge of the authenticated identity in the ssh-agent.
+	receipt *receipt.Receipt
+	// origEUID is the effective user ID of the PAM application before the authentication, which saves the receipts.
+	origEUID int
 }
 
-func newAuthenticator(user, home string) *authenticator {
+func newAuthenticator(user, home, service, remoteAddr, tty string) *authenticator {
 	// Initialize config.
-	parser := conf.NewParser(user, home)
+	parser := conf.NewParser(user, home, service)
 	config := parser.ParseConfigFile(configPath)
 
-	// Initialize system logger.
-	// FIXME(darwin): sysLogger output is lost on macOS due to
-	// https://github.com/golang/go/issues/59229
-	sysLogger, err := syslog.New(syslog.LOG_AUTHPRIV, "PAM_SSHCA")
-	if err != nil {
-		msg.Printlf(msg.WARN, "Failed to access syslogd, please fix your system logs.")
-		sysLogger = nil
-	}
-
 	return &authenticator{
-		user:      user,
-		home:      home,
-		config:    &config,
-		sysLogger: sysLogger,
+		user:       user,
+		home:       home,
+		service:    service,
+		remoteAddr: remoteAddr,
+		tty:        tty,
+		cmd:        getCmdLine(os.Getpid()),
+		config:     &config,
+		auditor:    newAudit(config.AuditSinks),
+		nonceDB:    nonce.NewDB(nonce.DefaultPath),
+		prompter:   msg.NewPrompter(),
 	}
 }
 
 func (a *authenticator) authenticate() C.int {
-	cmd := getCmdLine(os.Getpid())
+	// Apply the policies that require stronger credentials for the command.
+	if err := a.applyCommandPolicies(); err != nil {
+		msg.Printlf(msg.FATAL, "Command policy check failed: %v", err)
+		a.record(&audit.Event{Type: audit.TypeDeny, Reason: audit.ReasonCommandPolicy, Error: err.Error()})
+		return C.PAM_AUTH_ERR
+	}
 
 	// Initialize ssh-agent.
 	sshAuthSock, err := sshagent.CheckSSHAuthSock()
 	if err != nil {
-		authNFn := NonSSHAgentAuthN()
-		authNErr := authNFn(a.user, *a.config, a.sysLogger)
-		if authNErr != nil {
-			msg.Printlf(msg.FATAL, "Cannot find SSH agent: %v", err)
-			msg.Printlf(msg.FATAL, "Non-ssh-agent authentication failed: %v", authNErr)
-			return C.PAM_AUTH_ERR
-		}
-		return C.PAM_SUCCESS
+		return a.fallback(conf.FallbackNoAgent, fmt.Errorf("cannot find SSH agent: %v", err), C.PAM_AUTH_ERR)
 	}
 
 	conn, err := net.Dial("unix", sshAuthSock)
 	if err != nil {
-		msg.Printlf(msg.FATAL, "Cannot connect to SSH agent: %v", err)
-		return C.PAM_CRED_UNAVAIL
+		return a.fallback(conf.FallbackNoAgent, fmt.Errorf("cannot connect to SSH agent: %v", err), C.PAM_CRED_UNAVAIL)
 	}
 	defer conn.Close()
 	ag := agent.NewClient(conn)
@@ -102,8 +118,7 @@ func (a *authenticator) authenticate() C.int {
 	// Fetch all the identities from ssh-agent.
 	identities, err := getIdentitiesFromSSHAgent(ag)
 	if err != nil {
-		msg.Printlf(msg.FATAL, "Failed to get keys from sshagent: %v", err)
-		return C.PAM_CRED_UNAVAIL
+		return a.fallback(conf.FallbackNoAgent, fmt.Errorf("failed to get keys from sshagent: %v", err), C.PAM_CRED_UNAVAIL)
 	}
 
 	msg.Printlf(msg.DEBUG, "Found %d identities in current SSH agent.", len(identities))
@@ -119,7 +134,16 @@ func (a *authenticator) authenticate() C.int {
 	// Authenticate using static keys.
 	if a.config.AllowStaticKeys {
 		if key := a.authStaticKey(ag, identities); key != nil {
-			a.sysLogInfo(fmt.Sprintf("Grant: USER=%s, STATIC_KEY=%s, CMD=(%s)", a.user, bytes.TrimSpace(ssh.MarshalAuthorizedKey(key)), cmd))
+			if err := a.saveReceipt(a.receipt); err != nil {
+				msg.Printlf(msg.FATAL, "Static key authentication failed: %v", err)
+				e := &audit.Event{Type: audit.TypeDeny, Reason: audit.ReasonReceiptFailed, Error: err.Error()}
+				e.SetKey(key)
+				a.record(e)
+				return C.PAM_AUTH_ERR
+			}
+			e := &audit.Event{Type: audit.TypeGrant, Reason: audit.ReasonStaticKey}
+			e.SetKey(key)
+			a.record(e)
 			return C.PAM_SUCCESS
 		}
 	}
@@ -127,25 +151,56 @@ func (a *authenticator) authenticate() C.int {
 	// Authenticate using certificates.
 	if a.config.AllowCertificate {
 		if cert := a.authCertificate(ag, identities, a.user); cert != nil {
-			a.sysLogInfo(fmt.Sprintf("Grant: USER=%s, KEYID=(%s), CMD=(%s)", a.user, cert.KeyId, cmd))
+			reason := audit.ReasonApprovalFailed
+			err := a.approve(cert)
+			if err == nil {
+				reason = audit.ReasonReceiptFailed
+				err = a.saveReceipt(a.receipt)
+			}
+			if err != nil {
+				msg.Printlf(msg.FATAL, "Certificate authentication failed: %v", err)
+				e := &audit.Event{Type: audit.TypeDeny, Reason: reason, Error: err.Error()}
+				e.SetKey(cert)
+				a.record(e)
+				return C.PAM_AUTH_ERR
+			}
+			e := &audit.Event{Type: audit.TypeGrant, Reason: audit.ReasonCertificate}
+			e.SetKey(cert)
+			a.record(e)
 			return C.PAM_SUCCESS
 		}
 	}
 
-	a.sysLogWarning(fmt.Sprintf("Deny: USER=%s, CMD=(%s)", a.user, cmd))
-	return C.PAM_AUTH_ERR
+	if a.challenged {
+		return a.fallback(conf.FallbackChallengeFailed, fmt.Errorf("challenges of all the valid identities failed"), C.PAM_AUTH_ERR)
+	}
+	return a.fallback(conf.FallbackNoValidIdentity, fmt.Errorf("no valid identity in SSH agent"), C.PAM_AUTH_ERR)
 }
 
-func (a *authenticator) sysLogInfo(m string) {
-	if a.sysLogger != nil {
-		a.sysLogger.Info(m) //nolint:errcheck
+// fallback runs the fallback authentication methods if FallbackOn includes the condition.
+// Otherwise, or if the fallback authentication fails, it denies the request and returns code.
+func (a *authenticator) fallback(condition string, cause error, code C.int) C.int {
+	if !a.config.ShouldFallback(condition) {
+		msg.Printlf(msg.FATAL, "Authentication failed: %v", cause)
+		a.record(&audit.Event{Type: audit.TypeDeny, Reason: condition, Error: cause.Error()})
+		return code
 	}
-}
 
-func (a *authenticator) sysLogWarning(m string) {
-	if a.sysLogger != nil {
-		a.sysLogger.Warning(m) //nolint:errcheck
+	msg.Printlf(msg.DEBUG, "Fallback on %s: %v", condition, cause)
+	authNFn := NonSSHAgentAuthN()
+	if err := authNFn(a.user, *a.config, &Validator{a: a}); err != nil {
+		msg.Printlf(msg.FATAL, "%v", cause)
+		msg.Printlf(msg.FATAL, "Non-ssh-agent authentication failed: %v", err)
+		e := &audit.Event{Type: audit.TypeDeny, Reason: audit.ReasonFallbackFailed, Error: err.Error()}
+		if errors.Is(err, ErrMaxTries) {
+			e.Reason = audit.ReasonMaxTries
+			a.record(e)
+			return C.PAM_MAXTRIES
+		}
+		a.record(e)
+		return C.PAM_AUTH_ERR
 	}
+	return C.PAM_SUCCESS
 }
 
 // Authenticate is the entry of Go language part.
@@ -156,6 +211,9 @@ func Authenticate(pamh *C.pam_handle_t) C.int {
 	// Initialize login variables.
 	user := C.GoString(C.GetCurrentUserName(pamh))
 	home := C.GoString(C.GetCurrentUserHome(pamh)) + "/"
+	service := C.GoString(C.GetItem(pamh, C.PAM_SERVICE))
+	remoteAddr := C.GoString(C.GetItem(pamh, C.PAM_RHOST))
+	tty := C.GoString(C.GetItem(pamh, C.PAM_TTY))
 
 	// Set correct euid before authentication.
 	// NOTE: https://hackerone.com/reports/204802
@@ -164,6 +222,7 @@ func Authenticate(pamh *C.pam_handle_t) C.int {
 	uid := C.GetCurrentUserUID(pamh)
 	syscall.Setreuid(-1, int(uid)) //nolint:errcheck
 
-	authenticator := newAuthenticator(user, home)
+	authenticator := newAuthenticator(user, home, service, remoteAddr, tty)
+	authenticator.origEUID = origEUID
 	return authenticator.authenticate()
 }
@@ -0,0 +1,46 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package pam
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/theparanoids/pam-ysshca/msg"
+)
+
+const confirmPrompt = "The command requires confirmation:\n\n\t%s\n\nType \"yes\" to continue:"
+
+// applyCommandPolicies applies the CommandPolicy directives that match the command.
+// It forbids static keys and adds the Key ID rules required by the matching policies to the config,
+// and asks the user to confirm the command if any matching policy requires it.
+// Static keys are forbidden by any policy with Key ID rules as well, because the rules apply to certificates only.
+// The policies apply only when the PAM application authenticates, which sudo skips while its credentials are cached.
+func (a *authenticator) applyCommandPolicies() error {
+	confirm := false
+	for _, policy := range a.config.CommandPolicies {
+		if !policy.Match(string(a.cmd)) {
+			continue
+		}
+		msg.Printlf(msg.DEBUG, "Command policy %+v matches the command", policy)
+		if policy.NoStaticKeys || len(policy.KeyIDRules) != 0 {
+			a.config.AllowStaticKeys = false
+		}
+		a.config.KeyIDRules = append(a.config.KeyIDRules, policy.KeyIDRules...)
+		confirm = confirm || policy.Confirm
+	}
+	if !confirm {
+		return nil
+	}
+
+	a.prompter.Promptf(confirmPrompt, a.cmd)
+	answer, err := a.prompter.ReadString()
+	if err != nil {
+		return err
+	}
+	if strings.ToLower(answer) != "yes" {
+		return fmt.Errorf("command is not confirmed")
+	}
+	return nil
+}
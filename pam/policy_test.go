@@ -0,0 +1,86 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package pam
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/theparanoids/pam-ysshca/conf"
+	"github.com/theparanoids/pam-ysshca/msg"
+)
+
+func Test_authenticator_applyCommandPolicies(t *testing.T) {
+	touch := conf.KeyIDRule{Field: "touchPolicy", Op: ">=", Value: "2"}
+	policies := []conf.CommandPolicy{
+		{Glob: "rm *", KeyIDRules: []conf.KeyIDRule{touch}, NoStaticKeys: true},
+		{Glob: "shutdown *", KeyIDRules: []conf.KeyIDRule{touch}},
+		{RE: regexp.MustCompile("^reboot"), Confirm: true},
+	}
+	tests := []struct {
+		name           string
+		cmd            string
+		input          string
+		wantRules      int
+		wantStaticKeys bool
+		wantErr        bool
+	}{
+		{
+			name:           "no matching policy",
+			cmd:            "sudo ls",
+			wantStaticKeys: true,
+		},
+		{
+			name:      "step-up policy",
+			cmd:       "sudo rm -rf /tmp/x",
+			wantRules: 1,
+		},
+		{
+			// The Key ID rules apply to certificates only, so static keys are refused as well.
+			name:      "step-up policy implies no static keys",
+			cmd:       "sudo shutdown -h now",
+			wantRules: 1,
+		},
+		{
+			name:           "confirmed",
+			cmd:            "sudo reboot now",
+			input:          "yes\n",
+			wantStaticKeys: true,
+		},
+		{
+			name:           "not confirmed",
+			cmd:            "sudo reboot now",
+			input:          "no\n",
+			wantStaticKeys: true,
+			wantErr:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &authenticator{
+				cmd: []byte(tt.cmd),
+				config: &conf.Config{
+					AllowStaticKeys: true,
+					CommandPolicies: policies,
+				},
+				prompter: msg.NewPrompterWithReader(strings.NewReader(tt.input)),
+			}
+			if err := a.applyCommandPolicies(); (err != nil) != tt.wantErr {
+				t.Fatalf("applyCommandPolicies() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(a.config.KeyIDRules) != tt.wantRules {
+				t.Errorf("applyCommandPolicies() KeyIDRules = %v, want %d rules", a.config.KeyIDRules, tt.wantRules)
+			}
+			if a.config.AllowStaticKeys != tt.wantStaticKeys {
+				t.Errorf("applyCommandPolicies() AllowStaticKeys = %v, want %v", a.config.AllowStaticKeys, tt.wantStaticKeys)
+			}
+			if !tt.wantStaticKeys && !tt.wantErr {
+				if err := (&Validator{a: a}).CheckStaticKey(testPubKeys(t)[0]); err == nil {
+					t.Errorf("CheckStaticKey() expected error for the command that requires stronger credentials")
+				}
+			}
+		})
+	}
+}
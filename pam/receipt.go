@@ -0,0 +1,166 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package pam
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/theparanoids/pam-ysshca/audit"
+	"github.com/theparanoids/pam-ysshca/msg"
+	"github.com/theparanoids/pam-ysshca/sshutils/challenge"
+	"github.com/theparanoids/pam-ysshca/sshutils/receipt"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// agentChallengeTTL is the time before the challenge of the ssh-agent expires.
+// The ssh-agent signs the challenge without the user's input, except for touching the hardware key.
+const agentChallengeTTL = time.Minute
+
+// The options of sudo(8) and su(1) that take a value, which is not the target user.
+var (
+	sudoValueOptions = map[string]bool{
+		"-C": true, "-D": true, "-g": true, "-h": true, "-p": true, "-R": true, "-r": true, "-T": true, "-t": true, "-U": true,
+		"--close-from": true, "--chdir": true, "--group": true, "--host": true, "--prompt": true, "--chroot": true,
+		"--role": true, "--command-timeout": true, "--type": true, "--other-user": true,
+	}
+	suValueOptions = map[string]bool{
+		"-c": true, "-g": true, "-G": true, "-s": true, "-w": true,
+		"--command": true, "--group": true, "--supp-group": true, "--shell": true, "--whitelist-environment": true,
+		"--session-command": true,
+	}
+)
+
+// challengeContext returns the context of the current authentication request for the principal,
+// which is the statement that the user signs to approve the request.
+func (a *authenticator) challengeContext(principal string) challenge.Context {
+	host, _ := os.Hostname()
+	return challenge.Context{
+		Host:        host,
+		User:        principal,
+		Service:     a.service,
+		CommandHash: challenge.HashCommand(string(a.cmd)),
+		Target:      targetUser(a.service, a.cmd),
+		Command:     string(a.cmd),
+	}
+}
+
+// challengeSSHAgent challenges the key in the ssh-agent with the context of the current authentication request.
+// The signed context is kept as the receipt of the grant.
+func (a *authenticator) challengeSSHAgent(ag agent.Agent, key ssh.PublicKey) error {
+	ch, err := challenge.NewContextChallenge(key, a.challengeContext(a.user), agentChallengeTTL)
+	if err != nil {
+		return err
+	}
+	sig, err := challenge.SignWithAgent(ag, key, ch.Payload())
+	if err != nil {
+		return err
+	}
+	if err := ch.VerifySignature(sig); err != nil {
+		return err
+	}
+	a.receipt, err = receipt.New(ch)
+	return err
+}
+
+// saveReceipt saves the receipt of the grant in ReceiptDir, if ReceiptDir is set.
+// The receipt is written by the original effective user of the PAM application (root for sudo),
+// so that the user cannot tamper with the receipts.
+func (a *authenticator) saveReceipt(r *receipt.Receipt) error {
+	if a.config.ReceiptDir == "" {
+		return nil
+	}
+	if r == nil {
+		return fmt.Errorf("no receipt for the grant")
+	}
+	var path string
+	err := a.asOrigEUID(func() (err error) {
+		path, err = receipt.Save(a.config.ReceiptDir, r)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save receipt: %v", err)
+	}
+	msg.Printlf(msg.DEBUG, "Saved receipt %s", path)
+	a.record(&audit.Event{Type: audit.TypeReceipt, Details: map[string]string{"receipt": path}})
+	return nil
+}
+
+// targetUser returns the user that the command line of the PAM service runs as,
+// such as the user of `sudo -u` and `su`. It returns an empty string if the target user is unknown.
+func targetUser(service string, cmd []byte) string {
+	args := strings.Fields(string(cmd))
+	if len(args) == 0 {
+		return ""
+	}
+	switch service {
+	case "sudo":
+		if user, found, _ := parseArgs(args[1:], sudoValueOptions, "-u", "--user"); found {
+			return user
+		}
+		return "root"
+	case "su", "su-l":
+		if _, _, operands := parseArgs(args[1:], suValueOptions, "", ""); len(operands) > 0 {
+			return operands[0]
+		}
+		return "root"
+	}
+	return ""
+}
+
+// parseArgs returns the value of the short option short or of the long option long in the arguments,
+// and the operands after the options. valueOptions are the other options that take a value.
+func parseArgs(args []string, valueOptions map[string]bool, short, long string) (value string, found bool, operands []string) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			return "", false, args[i+1:]
+		case arg == "-":
+			// `su -` is the same as `su -l`.
+		case strings.HasPrefix(arg, "--"):
+			name, value, hasValue := strings.Cut(arg, "=")
+			if long != "" && name == long {
+				if hasValue {
+					return value, true, nil
+				}
+				return nextArg(args, i), true, nil
+			}
+			if valueOptions[name] && !hasValue {
+				i++
+			}
+		case strings.HasPrefix(arg, "-"):
+			// Short options may be grouped, such as `sudo -iu alice`.
+			for j := 1; j < len(arg); j++ {
+				opt := "-" + arg[j:j+1]
+				if !valueOptions[opt] && opt != short {
+					continue
+				}
+				value := arg[j+1:]
+				if value == "" {
+					value = nextArg(args, i)
+					i++
+				}
+				if opt == short {
+					return value, true, nil
+				}
+				break
+			}
+		default:
+			return "", false, args[i:]
+		}
+	}
+	return "", false, nil
+}
+
+// nextArg returns the argument after index i, or an empty string if there is none.
+func nextArg(args []string, i int) string {
+	if i+1 < len(args) {
+		return args[i+1]
+	}
+	return ""
+}
@@ -0,0 +1,104 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package pam
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/theparanoids/pam-ysshca/conf"
+	"github.com/theparanoids/pam-ysshca/sshutils/receipt"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func Test_targetUser(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		service string
+		cmd     string
+		want    string
+	}{
+		{name: "sudo default", service: "sudo", cmd: "sudo ls -u alice", want: "root"},
+		{name: "sudo -u", service: "sudo", cmd: "sudo -u alice ls", want: "alice"},
+		{name: "sudo grouped options", service: "sudo", cmd: "sudo -iualice", want: "alice"},
+		{name: "sudo --user", service: "sudo", cmd: "sudo --user=alice -i", want: "alice"},
+		{name: "sudo option with value", service: "sudo", cmd: "sudo -p prompt -u alice ls", want: "alice"},
+		{name: "sudo option with grouped value", service: "sudo", cmd: "sudo -gwheel ls", want: "root"},
+		{name: "sudo end of options", service: "sudo", cmd: "sudo -- -u alice", want: "root"},
+		{name: "su default", service: "su", cmd: "su -", want: "root"},
+		{name: "su user", service: "su", cmd: "su - alice", want: "alice"},
+		{name: "su option with value", service: "su", cmd: "su -s /bin/sh alice -c id", want: "alice"},
+		{name: "unknown service", service: "sshd", cmd: "sshd: alice", want: ""},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := targetUser(tt.service, []byte(tt.cmd)); got != tt.want {
+				t.Errorf("targetUser() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_authenticator_saveReceipt(t *testing.T) {
+	t.Parallel()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshAgent := agent.NewKeyring()
+	if err := sshAgent.Add(agent.AddedKey{PrivateKey: priv}); err != nil {
+		t.Fatal(err)
+	}
+	pub, err := ssh.NewPublicKey(priv.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "receipts")
+	a := &authenticator{
+		user:     "alice",
+		service:  "sudo",
+		cmd:      []byte("sudo -u bob ls"),
+		config:   &conf.Config{ReceiptDir: dir},
+		origEUID: os.Geteuid(),
+	}
+	if err := a.challengeSSHAgent(sshAgent, pub); err != nil {
+		t.Fatalf("challengeSSHAgent() error = %v", err)
+	}
+	if err := a.saveReceipt(a.receipt); err != nil {
+		t.Fatalf("saveReceipt() error = %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("saved receipts = %v, err = %v, want 1 receipt", files, err)
+	}
+	r, err := receipt.Load(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, signer, err := r.Verify(nil)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if ctx.User != "alice" || ctx.Target != "bob" || ctx.Service != "sudo" || ctx.Command != "sudo -u bob ls" {
+		t.Errorf("Verify() statement = %+v", ctx)
+	}
+	if ssh.FingerprintSHA256(signer) != ssh.FingerprintSHA256(pub) {
+		t.Errorf("Verify() signer = %s, want %s", ssh.FingerprintSHA256(signer), ssh.FingerprintSHA256(pub))
+	}
+
+	// Without ReceiptDir, no receipt is required.
+	a.config = &conf.Config{}
+	if err := a.saveReceipt(nil); err != nil {
+		t.Errorf("saveReceipt() without ReceiptDir error = %v", err)
+	}
+}
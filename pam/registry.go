@@ -4,11 +4,14 @@
 package pam
 
 import (
+	"errors"
 	"fmt"
 	"log/syslog"
+	"strings"
 
 	"github.com/theparanoids/pam-ysshca/conf"
 	"github.com/theparanoids/pam-ysshca/filter"
+	"github.com/theparanoids/pam-ysshca/msg"
 )
 
 // AuthNFn is the interface to do authentication for the given principal.
@@ -19,26 +22,32 @@ import (
 // into PAM_SSHCA.
 // TODO: Investigate the cgo runtime issue again and check if there's a workaround to
 // integrate multiple cgo libraries into the same pam config.
+// New authentication methods should implement ValidatorAuthNFn instead.
 type AuthNFn func(principal string, config conf.Config, sysLogger *syslog.Writer) error
 
+// ValidatorAuthNFn is the interface of the fallback authentication methods added by AddFallbackAuthN.
+// The validator validates certificates with the same pipeline as the ssh-agent path, and records the audit events.
+// It returns an error wrapping ErrMaxTries if the user runs out of the attempts, which results in PAM_MAXTRIES.
+type ValidatorAuthNFn func(principal string, config conf.Config, validator *Validator) error
+
 var (
 	r = newRegistry()
+
+	// ErrMaxTries is wrapped by the errors of ValidatorAuthNFn if the user runs out of the attempts.
+	ErrMaxTries = errors.New("maximum number of tries exceeded")
 )
 
 // registry is the struct to stored callback functions or options from external packages.
-// External packages cannot invoke cgo functions, so we expose functions such as `AddFilter` and `SetNonSSHAgentAuthN`
+// External packages cannot invoke cgo functions, so we expose functions such as `AddFilter` and `AddFallbackAuthN`
 // to enable other repos or packages to insert self-defined logic into PAM-SSHCA.
 type registry struct {
-	filters          map[string]filter.Doer
-	nonSSHAgentAuthN AuthNFn
+	filters        map[string]filter.Doer
+	fallbackAuthNs []ValidatorAuthNFn
 }
 
 func newRegistry() *registry {
 	return &registry{
 		filters: map[string]filter.Doer{},
-		nonSSHAgentAuthN: func(principal string, config conf.Config, sysLogger *syslog.Writer) error {
-			return fmt.Errorf("no non-ssh-agent authentication method found")
-		},
 	}
 }
 
@@ -55,12 +64,58 @@ func AddFilter(ft string, filter filter.Doer) {
 	r.filters[ft] = filter
 }
 
-// NonSSHAgentAuthN returns the method of the fallback authentication when the ssh-agent connection fails.
-func NonSSHAgentAuthN() AuthNFn {
-	return r.nonSSHAgentAuthN
+// NonSSHAgentAuthN returns the method of the fallback authentication, which runs
+// the chain of fallback authentication methods in order until one of them succeeds.
+// The conditions to run the fallback authentication are specified by FallbackOn directive.
+func NonSSHAgentAuthN() ValidatorAuthNFn {
+	chain := r.fallbackAuthNs
+	return func(principal string, config conf.Config, validator *Validator) error {
+		if len(chain) == 0 {
+			return fmt.Errorf("no non-ssh-agent authentication method found")
+		}
+		var errs chainError
+		for i, fn := range chain {
+			err := fn(principal, config, validator)
+			if err == nil {
+				return nil
+			}
+			msg.Printlf(msg.DEBUG, "Fallback authentication method %d failed: %v", i, err)
+			errs = append(errs, err)
+		}
+		return errs
+	}
 }
 
-// SetNonSSHAgentAuthN sets the fallback authentication method when the ssh-agent connection fails.
+// chainError is the error of the chain of fallback authentication methods, which wraps the errors of all the methods.
+type chainError []error
+
+func (e chainError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "all fallback authentication methods failed: " + strings.Join(msgs, "; ")
+}
+
+func (e chainError) Unwrap() []error {
+	return e
+}
+
+// SetNonSSHAgentAuthN sets the fallback authentication method, replacing the chain of fallback authentication methods.
+// The method gets a writer to the local syslog, or nil if syslogd is not accessible.
 func SetNonSSHAgentAuthN(fn AuthNFn) {
-	r.nonSSHAgentAuthN = fn
+	r.fallbackAuthNs = []ValidatorAuthNFn{func(principal string, config conf.Config, _ *Validator) error {
+		sysLogger, err := syslog.New(syslog.LOG_AUTHPRIV, "PAM_SSHCA")
+		if err != nil {
+			msg.Printlf(msg.WARN, "Failed to access syslogd, please fix your system logs.")
+			return fn(principal, config, nil)
+		}
+		defer sysLogger.Close()
+		return fn(principal, config, sysLogger)
+	}}
+}
+
+// AddFallbackAuthN appends the authentication method to the chain of fallback authentication methods.
+func AddFallbackAuthN(fn ValidatorAuthNFn) {
+	r.fallbackAuthNs = append(r.fallbackAuthNs, fn)
 }
@@ -0,0 +1,96 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package pam
+
+import (
+	"errors"
+	"fmt"
+	"log/syslog"
+	"reflect"
+	"testing"
+
+	"github.com/theparanoids/pam-ysshca/conf"
+)
+
+func TestNonSSHAgentAuthN(t *testing.T) {
+	defer func(orig *registry) { r = orig }(r)
+
+	var called []string
+	authN := func(name string, err error) ValidatorAuthNFn {
+		return func(principal string, config conf.Config, validator *Validator) error {
+			called = append(called, name)
+			return err
+		}
+	}
+	tests := []struct {
+		name       string
+		setup      func()
+		wantCalled []string
+		wantErr    bool
+		// wantMaxTries is true if the error should wrap ErrMaxTries.
+		wantMaxTries bool
+	}{
+		{
+			name:    "no fallback authentication method",
+			setup:   func() {},
+			wantErr: true,
+		},
+		{
+			name: "chain stops at the first success",
+			setup: func() {
+				AddFallbackAuthN(authN("first", errors.New("failed")))
+				AddFallbackAuthN(authN("second", nil))
+				AddFallbackAuthN(authN("third", nil))
+			},
+			wantCalled: []string{"first", "second"},
+		},
+		{
+			name: "all methods fail",
+			setup: func() {
+				AddFallbackAuthN(authN("first", errors.New("failed")))
+				AddFallbackAuthN(authN("second", errors.New("failed")))
+			},
+			wantCalled: []string{"first", "second"},
+			wantErr:    true,
+		},
+		{
+			name: "one method runs out of the attempts",
+			setup: func() {
+				AddFallbackAuthN(authN("first", fmt.Errorf("%w: bad signature", ErrMaxTries)))
+				AddFallbackAuthN(authN("second", errors.New("failed")))
+			},
+			wantCalled:   []string{"first", "second"},
+			wantErr:      true,
+			wantMaxTries: true,
+		},
+		{
+			name: "set replaces the chain",
+			setup: func() {
+				AddFallbackAuthN(authN("first", errors.New("failed")))
+				SetNonSSHAgentAuthN(func(principal string, config conf.Config, sysLogger *syslog.Writer) error {
+					called = append(called, "replaced")
+					return nil
+				})
+			},
+			wantCalled: []string{"replaced"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r = newRegistry()
+			called = nil
+			tt.setup()
+			err := NonSSHAgentAuthN()("user", conf.Config{}, nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NonSSHAgentAuthN() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if errors.Is(err, ErrMaxTries) != tt.wantMaxTries {
+				t.Errorf("NonSSHAgentAuthN() error = %v, wantMaxTries %v", err, tt.wantMaxTries)
+			}
+			if !reflect.DeepEqual(called, tt.wantCalled) {
+				t.Errorf("NonSSHAgentAuthN() called %v, want %v", called, tt.wantCalled)
+			}
+		})
+	}
+}
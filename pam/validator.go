@@ -0,0 +1,101 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package pam
+
+import (
+	"fmt"
+
+	"github.com/theparanoids/pam-ysshca/audit"
+	"github.com/theparanoids/pam-ysshca/msg"
+	"github.com/theparanoids/pam-ysshca/sshutils/challenge"
+	"github.com/theparanoids/pam-ysshca/sshutils/receipt"
+	"golang.org/x/crypto/ssh"
+)
+
+// Validator validates the certificates for the current authentication request with the same pipeline
+// as the ssh-agent path, so that a certificate accepted by one authentication method is accepted by the others.
+// It is passed to the fallback authentication methods (see ValidatorAuthNFn).
+type Validator struct {
+	a *authenticator
+}
+
+// CheckCert returns nil if the certificate is valid for the principal (the user to authenticate).
+// The certificate is checked by the filters, the trusted CAs, the authorized principals and
+// all the certificate policies in the config.
+func (v *Validator) CheckCert(cert *ssh.Certificate, principal string) error {
+	a := v.a
+	if !a.config.AllowCertificate {
+		return fmt.Errorf("certificates are not allowed")
+	}
+	for _, filter := range a.config.Filters {
+		if len(invokeFilter([]ssh.PublicKey{cert}, filter)) == 0 {
+			return fmt.Errorf("certificate is dropped by filter %s", filter)
+		}
+	}
+
+	principals, err := a.config.AuthorizedPrincipals(principal)
+	if err != nil {
+		msg.Printlf(msg.WARN, "Failed parsing additional authorized principals file: %v", err)
+	}
+	caKeys := newPublicKeyMap()
+	if err := caKeys.load(a.config.CAKeys); err != nil {
+		return fmt.Errorf("failed to load trusted CA keys: %v", err)
+	}
+	return a.validateCertificate(cert, principal, principals, caKeys)
+}
+
+// CheckStaticKey returns nil if the public key is a valid static key for the user to authenticate,
+// which passes the filters and is authorized by AuthorizedKeysFile or AuthorizedKeysCommand.
+func (v *Validator) CheckStaticKey(key ssh.PublicKey) error {
+	a := v.a
+	if !a.config.AllowStaticKeys {
+		return fmt.Errorf("static keys are not allowed")
+	}
+	for _, filter := range a.config.Filters {
+		if len(invokeFilter([]ssh.PublicKey{key}, filter)) == 0 {
+			return fmt.Errorf("public key is dropped by filter %s", filter)
+		}
+	}
+	if len(a.getValidStaticKeys([]ssh.PublicKey{key})) == 0 {
+		return fmt.Errorf("public key is not authorized")
+	}
+	return nil
+}
+
+// ChallengeContext returns the context of the current authentication request for the principal
+// (the user to authenticate), which binds the challenges of the fallback authentication methods to the request.
+func (v *Validator) ChallengeContext(principal string) challenge.Context {
+	return v.a.challengeContext(principal)
+}
+
+// Prompt returns the message of the Prompt directive that matches the certificate,
+// which should be printed before challenging the certificate. It is empty if no Prompt directive matches.
+func (v *Validator) Prompt(cert *ssh.Certificate) string {
+	return v.a.promptMessage(cert)
+}
+
+// Prompter returns the Prompter of the authentication request, which the authentication methods must read
+// the input of users by. The Prompter may keep reading the input in background once a deadline is set,
+// so a second Prompter on the same input would lose the lines, such as the justification of a firefighter certificate.
+func (v *Validator) Prompter() *msg.Prompter {
+	return v.a.prompter
+}
+
+// Approve completes the authentication by the certificate after its challenge succeeds.
+// It records the use of a nonce certificate, and requires a justification for a firefighter certificate.
+func (v *Validator) Approve(cert *ssh.Certificate) error {
+	return v.a.approve(cert)
+}
+
+// SaveReceipt saves the receipt of the grant in ReceiptDir, if ReceiptDir is set.
+// The grant must be denied if it fails, so that every grant has a receipt.
+func (v *Validator) SaveReceipt(r *receipt.Receipt) error {
+	return v.a.saveReceipt(r)
+}
+
+// Record records the audit event of the authentication method, which is filled with the fields of the
+// current authentication request, such as the user, the service and the command.
+func (v *Validator) Record(e *audit.Event) error {
+	return v.a.recordEvent(e)
+}
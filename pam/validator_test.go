@@ -0,0 +1,123 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package pam
+
+import (
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/theparanoids/pam-ysshca/conf"
+	"github.com/theparanoids/pam-ysshca/filter"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestValidator_CheckCert(t *testing.T) {
+	addedCerts := testCerts(t)
+	tmp, err := os.CreateTemp(t.TempDir(), "pam-sshca-unit-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tmp.Write(ssh.MarshalAuthorizedKey(addedCerts[0].Certificate.SignatureKey)); err != nil {
+		t.Fatal(err)
+	}
+
+	a := &authenticator{
+		config: &conf.Config{
+			AllowCertificate: true,
+			CAKeys:           []string{tmp.Name()},
+		},
+	}
+	v := &Validator{a: a}
+
+	// The validator accepts the same certificates as getValidCertificates.
+	var identities []ssh.PublicKey
+	for _, added := range addedCerts {
+		identities = append(identities, added.Certificate)
+	}
+	valid := a.getValidCertificates(identities, "4")
+	if len(valid) != 1 {
+		t.Fatalf("getValidCertificates() got %d certificates, want 1", len(valid))
+	}
+	for i, added := range addedCerts {
+		err := v.CheckCert(added.Certificate, "4")
+		if accepted := err == nil; accepted != (added.Certificate == valid[0]) {
+			t.Errorf("CheckCert() of certificate %d error = %v, inconsistent with getValidCertificates()", i, err)
+		}
+	}
+
+	// The certificates dropped by the filters are rejected.
+	AddFilter("validator-filter-out", &filterOut{})
+	a.config.Filters = []string{filter.EmbeddedPrefix + "validator-filter-out"}
+	if err := v.CheckCert(valid[0], "4"); err == nil {
+		t.Errorf("CheckCert() expected error for the certificate dropped by filter")
+	}
+}
+
+func TestValidator_CheckStaticKey(t *testing.T) {
+	t.Parallel()
+	keys := testPubKeys(t)
+	tmp, err := os.CreateTemp(t.TempDir(), "pam-sshca-unit-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tmp.Write(ssh.MarshalAuthorizedKey(keys[0])); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &conf.Config{
+		AllowStaticKeys: true,
+		StaticKeys:      []string{tmp.Name()},
+	}
+	v := &Validator{a: &authenticator{config: config}}
+	if err := v.CheckStaticKey(keys[0]); err != nil {
+		t.Errorf("CheckStaticKey() unexpected error: %v", err)
+	}
+	if err := v.CheckStaticKey(keys[1]); err == nil {
+		t.Errorf("CheckStaticKey() expected error for unauthorized key")
+	}
+	config.AllowStaticKeys = false
+	if err := v.CheckStaticKey(keys[0]); err == nil {
+		t.Errorf("CheckStaticKey() expected error when static keys are not allowed")
+	}
+}
+
+func TestValidator_Prompt(t *testing.T) {
+	t.Parallel()
+	v := &Validator{a: &authenticator{
+		config: &conf.Config{
+			Prompters: []conf.Prompter{
+				{KeyIDProperty: "touchPolicy", RE: regexp.MustCompile("(2|3)"), Message: "Touch YubiKey:"},
+				{KeyIDProperty: "touchPolicy", RE: regexp.MustCompile("."), Message: "Authenticating..."},
+			},
+		},
+	}}
+	tests := []struct {
+		name  string
+		keyID string
+		want  string
+	}{
+		{
+			name:  "first matching prompter",
+			keyID: `{"touchPolicy":3}`,
+			want:  "Touch YubiKey:",
+		},
+		{
+			name:  "second matching prompter",
+			keyID: `{"touchPolicy":1}`,
+			want:  "Authenticating...",
+		},
+		{
+			name:  "invalid key ID",
+			keyID: "invalid",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := v.Prompt(&ssh.Certificate{KeyId: tt.keyID}); got != tt.want {
+				t.Errorf("Prompt() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,555 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+// Package qrcode encodes data in QR codes (ISO/IEC 18004) and renders them in terminals,
+// so that the payloads of cryptoauth can be scanned from the screen instead of copied and pasted.
+// It supports the byte mode with error correction level L and M, which is all that cryptoauth needs.
+package qrcode
+
+import (
+	"errors"
+	"strings"
+)
+
+// Level is the error correction level of QR codes.
+type Level int
+
+const (
+	// Low recovers about 7% of the codewords.
+	Low Level = iota
+	// Medium recovers about 15% of the codewords.
+	Medium
+)
+
+const (
+	minVersion = 1
+	maxVersion = 40
+	// quietZone is the width of the light border around the symbol in modules.
+	// The standard asks for 4, but 2 is enough for scanners in practice and saves terminal space.
+	quietZone = 2
+)
+
+// ErrTooLong is returned when the data does not fit in a QR code of the highest version.
+var ErrTooLong = errors.New("qrcode: data too long")
+
+// formatBits are the error correction level bits of the format information.
+var formatBits = [...]int{Low: 1, Medium: 0}
+
+// eccCodewordsPerBlock is the number of error correction codewords in each block, indexed by level and version.
+var eccCodewordsPerBlock = [...][maxVersion + 1]int{
+	Low:    {-1, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	Medium: {-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+}
+
+// numErrorCorrectionBlocks is the number of error correction blocks, indexed by level and version.
+var numErrorCorrectionBlocks = [...][maxVersion + 1]int{
+	Low:    {-1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	Medium: {-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+}
+
+// Code is a QR code symbol.
+type Code struct {
+	version int
+	size    int
+	// modules are the dark (true) and light (false) modules, indexed by y and x.
+	modules [][]bool
+	// isFunction marks the modules of the function patterns, which are not masked.
+	isFunction [][]bool
+}
+
+// Encode returns the QR code of the data in byte mode, with the smallest version that fits the data at the level.
+func Encode(data []byte, level Level) (*Code, error) {
+	version := minVersion
+	for ; version <= maxVersion; version++ {
+		if len(data) <= capacity(version, level) {
+			break
+		}
+	}
+	if version > maxVersion {
+		return nil, ErrTooLong
+	}
+
+	codewords := interleave(dataCodewords(data, version, level), version, level)
+	c := newCode(version)
+	c.drawFunctionPatterns()
+	c.drawCodewords(codewords)
+
+	best, minPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormatBits(level, mask)
+		if penalty := c.penalty(); minPenalty < 0 || penalty < minPenalty {
+			best, minPenalty = mask, penalty
+		}
+		// Masking is an XOR, so applying it again undoes it.
+		c.applyMask(mask)
+	}
+	c.applyMask(best)
+	c.drawFormatBits(level, best)
+	return c, nil
+}
+
+// Size returns the width and height of the symbol in modules, excluding the quiet zone.
+func (c *Code) Size() int {
+	return c.size
+}
+
+// Dark returns true if the module at (x, y) is dark. The modules outside the symbol are light.
+func (c *Code) Dark(x, y int) bool {
+	return x >= 0 && x < c.size && y >= 0 && y < c.size && c.modules[y][x]
+}
+
+// Terminal returns the QR code drawn by Unicode half blocks, where each line holds two rows of modules.
+// The light modules are drawn as blocks, as for terminals with light text on dark background, such as `qrencode -t UTF8`.
+func (c *Code) Terminal() string {
+	var b strings.Builder
+	for y := -quietZone; y < c.size+quietZone; y += 2 {
+		for x := -quietZone; x < c.size+quietZone; x++ {
+			top, bottom := !c.Dark(x, y), !c.Dark(x, y+1)
+			// The bottom row of the last line is in the quiet zone if the height is odd.
+			switch {
+			case top && bottom:
+				b.WriteRune('█')
+			case top:
+				b.WriteRune('▀')
+			case bottom:
+				b.WriteRune('▄')
+			default:
+				b.WriteRune(' ')
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// capacity returns the maximum length of the data in byte mode in the QR code of the version and the level.
+func capacity(version int, level Level) int {
+	bits := numDataCodewords(version, level)*8 - 4 - charCountBits(version)
+	return bits / 8
+}
+
+// charCountBits returns the length of the character count indicator in byte mode.
+func charCountBits(version int) int {
+	if version <= 9 {
+		return 8
+	}
+	return 16
+}
+
+// numRawDataModules returns the number of modules for the codewords, excluding the function patterns.
+func numRawDataModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		numAlign := version/7 + 2
+		result -= (25*numAlign-10)*numAlign - 55
+		if version >= 7 {
+			result -= 36
+		}
+	}
+	return result
+}
+
+// numDataCodewords returns the number of data codewords, excluding the error correction codewords.
+func numDataCodewords(version int, level Level) int {
+	return numRawDataModules(version)/8 - eccCodewordsPerBlock[level][version]*numErrorCorrectionBlocks[level][version]
+}
+
+// dataCodewords returns the data codewords of the data in byte mode, including the terminator and the padding.
+func dataCodewords(data []byte, version int, level Level) []byte {
+	var bb bitBuffer
+	bb.append(0x4, 4) // byte mode
+	bb.append(len(data), charCountBits(version))
+	for _, d := range data {
+		bb.append(int(d), 8)
+	}
+	capacityBits := numDataCodewords(version, level) * 8
+	terminator := capacityBits - len(bb)
+	if terminator > 4 {
+		terminator = 4
+	}
+	bb.append(0, terminator)
+	bb.append(0, (8-len(bb)%8)%8)
+	for pad := 0xEC; len(bb) < capacityBits; pad ^= 0xEC ^ 0x11 {
+		bb.append(pad, 8)
+	}
+	return bb.bytes()
+}
+
+// interleave splits the data codewords in blocks, appends the error correction codewords to each block,
+// and returns the codewords of the blocks interleaved.
+func interleave(data []byte, version int, level Level) []byte {
+	numBlocks := numErrorCorrectionBlocks[level][version]
+	blockEccLen := eccCodewordsPerBlock[level][version]
+	rawCodewords := numRawDataModules(version) / 8
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortBlockLen := rawCodewords / numBlocks
+
+	divisor := reedSolomonDivisor(blockEccLen)
+	blocks := make([][]byte, numBlocks)
+	for i, k := 0, 0; i < numBlocks; i++ {
+		dataLen := shortBlockLen - blockEccLen
+		if i >= numShortBlocks {
+			dataLen++
+		}
+		block := append([]byte(nil), data[k:k+dataLen]...)
+		k += dataLen
+		ecc := reedSolomonRemainder(block, divisor)
+		if i < numShortBlocks {
+			// Pad the short blocks to interleave all the blocks by the same index.
+			block = append(block, 0)
+		}
+		blocks[i] = append(block, ecc...)
+	}
+
+	result := make([]byte, 0, rawCodewords)
+	for i := 0; i < shortBlockLen+1; i++ {
+		for j, block := range blocks {
+			if i != shortBlockLen-blockEccLen || j >= numShortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
+
+func newCode(version int) *Code {
+	size := version*4 + 17
+	c := &Code{
+		version:    version,
+		size:       size,
+		modules:    make([][]bool, size),
+		isFunction: make([][]bool, size),
+	}
+	for i := range c.modules {
+		c.modules[i] = make([]bool, size)
+		c.isFunction[i] = make([]bool, size)
+	}
+	return c
+}
+
+// setFunction sets the module at (x, y) of a function pattern.
+func (c *Code) setFunction(x, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.isFunction[y][x] = true
+}
+
+// drawFunctionPatterns draws the finder, timing and alignment patterns and the version information,
+// and reserves the modules of the format information.
+func (c *Code) drawFunctionPatterns() {
+	for i := 0; i < c.size; i++ {
+		c.setFunction(6, i, i%2 == 0)
+		c.setFunction(i, 6, i%2 == 0)
+	}
+
+	c.drawFinderPattern(3, 3)
+	c.drawFinderPattern(c.size-4, 3)
+	c.drawFinderPattern(3, c.size-4)
+
+	positions := c.alignmentPatternPositions()
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			// Skip the three corners of the finder patterns.
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			c.drawAlignmentPattern(x, y)
+		}
+	}
+
+	c.drawFormatBits(Low, 0)
+	c.drawVersion()
+}
+
+// drawFinderPattern draws the finder pattern and its separator centered at (x, y).
+func (c *Code) drawFinderPattern(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || xx >= c.size || yy < 0 || yy >= c.size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			c.setFunction(xx, yy, dist != 2 && dist != 4)
+		}
+	}
+}
+
+// drawAlignmentPattern draws the alignment pattern centered at (x, y).
+func (c *Code) drawAlignmentPattern(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			c.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// alignmentPatternPositions returns the ascending coordinates of the centers of the alignment patterns.
+func (c *Code) alignmentPatternPositions() []int {
+	if c.version == 1 {
+		return nil
+	}
+	numAlign := c.version/7 + 2
+	step := (c.version*8 + numAlign*3 + 5) / (numAlign*4 - 4) * 2
+	result := make([]int, numAlign)
+	result[0] = 6
+	for i, pos := numAlign-1, c.size-7; i >= 1; i, pos = i-1, pos-step {
+		result[i] = pos
+	}
+	return result
+}
+
+// drawFormatBits draws the two copies of the format information of the level and the mask.
+func (c *Code) drawFormatBits(level Level, mask int) {
+	data := formatBits[level]<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+
+	for i := 0; i <= 5; i++ {
+		c.setFunction(8, i, bit(bits, i))
+	}
+	c.setFunction(8, 7, bit(bits, 6))
+	c.setFunction(8, 8, bit(bits, 7))
+	c.setFunction(7, 8, bit(bits, 8))
+	for i := 9; i < 15; i++ {
+		c.setFunction(14-i, 8, bit(bits, i))
+	}
+
+	for i := 0; i < 8; i++ {
+		c.setFunction(c.size-1-i, 8, bit(bits, i))
+	}
+	for i := 8; i < 15; i++ {
+		c.setFunction(8, c.size-15+i, bit(bits, i))
+	}
+	// The dark module.
+	c.setFunction(8, c.size-8, true)
+}
+
+// drawVersion draws the two copies of the version information for version 7 or higher.
+func (c *Code) drawVersion() {
+	if c.version < 7 {
+		return
+	}
+	rem := c.version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	bits := c.version<<12 | rem
+	for i := 0; i < 18; i++ {
+		a, b := c.size-11+i%3, i/3
+		c.setFunction(a, b, bit(bits, i))
+		c.setFunction(b, a, bit(bits, i))
+	}
+}
+
+// drawCodewords draws the codewords in the zigzag order over the modules that are not function patterns.
+func (c *Code) drawCodewords(codewords []byte) {
+	i := 0
+	for right := c.size - 1; right >= 1; right -= 2 {
+		// Skip the vertical timing pattern.
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = c.size - 1 - vert
+				}
+				if !c.isFunction[y][x] && i < len(codewords)*8 {
+					c.modules[y][x] = bit(int(codewords[i>>3]), 7-(i&7))
+					i++
+				}
+			}
+		}
+	}
+}
+
+// applyMask flips the modules that are not function patterns by the mask pattern.
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; x++ {
+			var flip bool
+			switch mask {
+			case 0:
+				flip = (x+y)%2 == 0
+			case 1:
+				flip = y%2 == 0
+			case 2:
+				flip = x%3 == 0
+			case 3:
+				flip = (x+y)%3 == 0
+			case 4:
+				flip = (x/3+y/2)%2 == 0
+			case 5:
+				flip = x*y%2+x*y%3 == 0
+			case 6:
+				flip = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				flip = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if flip && !c.isFunction[y][x] {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty returns the penalty score of the symbol, which is minimized by the choice of the mask pattern.
+func (c *Code) penalty() int {
+	const (
+		penaltyN1 = 3
+		penaltyN2 = 3
+		penaltyN3 = 40
+		penaltyN4 = 10
+	)
+	result := 0
+	line := make([]bool, c.size)
+	for _, horizontal := range []bool{true, false} {
+		for i := 0; i < c.size; i++ {
+			for j := 0; j < c.size; j++ {
+				if horizontal {
+					line[j] = c.modules[i][j]
+				} else {
+					line[j] = c.modules[j][i]
+				}
+			}
+			// Runs of five or more modules of the same color.
+			for j := 0; j < c.size; {
+				k := j
+				for k < c.size && line[k] == line[j] {
+					k++
+				}
+				if run := k - j; run >= 5 {
+					result += penaltyN1 + run - 5
+				}
+				j = k
+			}
+			// Finder-like patterns 1:1:3:1:1 with four light modules on either side.
+			for j := 0; j+7 <= c.size; j++ {
+				if !isFinderLike(line[j : j+7]) {
+					continue
+				}
+				if lightRun(line, j-4, j) || lightRun(line, j+7, j+11) {
+					result += penaltyN3
+				}
+			}
+		}
+	}
+
+	dark := 0
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; x++ {
+			if c.modules[y][x] {
+				dark++
+			}
+			// Blocks of 2x2 modules of the same color.
+			if x+1 < c.size && y+1 < c.size {
+				color := c.modules[y][x]
+				if color == c.modules[y][x+1] && color == c.modules[y+1][x] && color == c.modules[y+1][x+1] {
+					result += penaltyN2
+				}
+			}
+		}
+	}
+	// The deviation of the proportion of dark modules from 50% in steps of 5%.
+	total := c.size * c.size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	result += k * penaltyN4
+	return result
+}
+
+// isFinderLike returns true if the seven modules are dark, light, dark, dark, dark, light, dark.
+func isFinderLike(m []bool) bool {
+	return m[0] && !m[1] && m[2] && m[3] && m[4] && !m[5] && m[6]
+}
+
+// lightRun returns true if the modules from start to end (exclusive) are light. The modules outside the line are light.
+func lightRun(line []bool, start, end int) bool {
+	for i := start; i < end; i++ {
+		if i >= 0 && i < len(line) && line[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// reedSolomonDivisor returns the coefficients of the generator polynomial of the degree,
+// excluding the leading term, from the highest to the lowest power.
+func reedSolomonDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+// reedSolomonRemainder returns the error correction codewords of the data for the divisor.
+func reedSolomonRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, d := range divisor {
+			result[i] ^= gfMultiply(d, factor)
+		}
+	}
+	return result
+}
+
+// gfMultiply returns the product of x and y in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+// bitBuffer is a sequence of bits.
+type bitBuffer []bool
+
+// append appends the lowest n bits of the value, from the highest bit.
+func (bb *bitBuffer) append(value, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*bb = append(*bb, bit(value, i))
+	}
+}
+
+// bytes returns the bits packed in bytes, from the highest bit.
+func (bb bitBuffer) bytes() []byte {
+	result := make([]byte, (len(bb)+7)/8)
+	for i, b := range bb {
+		if b {
+			result[i>>3] |= 0x80 >> (i & 7)
+		}
+	}
+	return result
+}
+
+// bit returns true if the i-th lowest bit of x is set.
+func bit(x, i int) bool {
+	return (x>>i)&1 != 0
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
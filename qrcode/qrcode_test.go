@@ -0,0 +1,117 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package qrcode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestEncode_Version(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		length      int
+		level       Level
+		wantVersion int
+		wantErr     error
+	}{
+		{name: "empty", length: 0, level: Low, wantVersion: 1},
+		{name: "full version 1-L", length: 17, level: Low, wantVersion: 1},
+		{name: "over version 1-L", length: 18, level: Low, wantVersion: 2},
+		{name: "full version 1-M", length: 14, level: Medium, wantVersion: 1},
+		{name: "over version 1-M", length: 15, level: Medium, wantVersion: 2},
+		{name: "16-bit character count", length: 231, level: Low, wantVersion: 10},
+		{name: "full version 40-L", length: 2953, level: Low, wantVersion: 40},
+		{name: "full version 40-M", length: 2331, level: Medium, wantVersion: 40},
+		{name: "too long", length: 2954, level: Low, wantErr: ErrTooLong},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Encode(bytes.Repeat([]byte("a"), tt.length), tt.level)
+			if err != tt.wantErr {
+				t.Fatalf("Encode() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if c.version != tt.wantVersion || c.Size() != tt.wantVersion*4+17 {
+				t.Errorf("Encode() version = %d size = %d, want version %d", c.version, c.Size(), tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestEncode_FunctionPatterns(t *testing.T) {
+	t.Parallel()
+	c, err := Encode([]byte("cryptoauth-client -u alice"), Medium)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The finder patterns at the three corners.
+	for _, corner := range [][2]int{{0, 0}, {c.size - 7, 0}, {0, c.size - 7}} {
+		for dy := 0; dy < 7; dy++ {
+			for dx := 0; dx < 7; dx++ {
+				dist := max(abs(dx-3), abs(dy-3))
+				if got, want := c.Dark(corner[0]+dx, corner[1]+dy), dist != 2; got != want {
+					t.Fatalf("module (%d, %d) of the finder pattern at %v is dark = %v, want %v", dx, dy, corner, got, want)
+				}
+			}
+		}
+	}
+	// The timing patterns.
+	for i := 8; i < c.size-8; i++ {
+		if c.Dark(i, 6) != (i%2 == 0) || c.Dark(6, i) != (i%2 == 0) {
+			t.Fatalf("module %d of the timing patterns is not alternating", i)
+		}
+	}
+	// The dark module.
+	if !c.Dark(8, c.size-8) {
+		t.Errorf("the dark module is light")
+	}
+	// The two copies of the format information are the same.
+	for i := 0; i < 8; i++ {
+		first := c.Dark(8, []int{0, 1, 2, 3, 4, 5, 7, 8}[i])
+		if second := c.Dark(c.size-1-i, 8); first != second {
+			t.Errorf("bit %d of the format information copies differ", i)
+		}
+	}
+}
+
+func TestReedSolomonRemainder(t *testing.T) {
+	t.Parallel()
+	// The data and error correction codewords of "HELLO WORLD" in version 1-M, from the standard's examples.
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := reedSolomonRemainder(data, reedSolomonDivisor(len(want))); !bytes.Equal(got, want) {
+		t.Errorf("reedSolomonRemainder() = %v, want %v", got, want)
+	}
+}
+
+func TestCode_Terminal(t *testing.T) {
+	t.Parallel()
+	c, err := Encode([]byte("pam-sshca"), Low)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(c.Terminal(), "\n"), "\n")
+	width := c.Size() + 2*quietZone
+	if want := (width + 1) / 2; len(lines) != want {
+		t.Errorf("Terminal() has %d lines, want %d", len(lines), want)
+	}
+	for i, line := range lines {
+		if n := utf8.RuneCountInString(line); n != width {
+			t.Errorf("line %d of Terminal() has %d characters, want %d", i, n, width)
+		}
+	}
+	// The first line is the quiet zone, and the second line starts with the top of the finder pattern.
+	if strings.Trim(lines[0], "█") != "" {
+		t.Errorf("Terminal() first line = %q, want the quiet zone", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "██ ▄▄▄▄▄ ") {
+		t.Errorf("Terminal() second line = %q, want the top of the finder pattern", lines[1])
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+// Package armor encodes the payloads exchanged by copy and paste, such as the certificates and the challenges
+// of cryptoauth, in PEM-like blocks. A block has a version, the length and the CRC-32 checksum of the payload,
+// and the base64 encoded payload wrapped in short lines, so that the payload wrapped or truncated by terminals,
+// tmux or chat tools is either recovered or reported with the part that is corrupted.
+//
+//	-----BEGIN PAM-SSHCA CHALLENGE-----
+//	Version: 1
+//	Length: 123
+//	Checksum: 1a2b3c4d
+//	eyJEYXRhIjoi...
+//	-----END PAM-SSHCA CHALLENGE-----
+package armor
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Version is the version of the armored blocks.
+	Version = 1
+	// LineWidth is the width of the lines of the base64 encoded payload.
+	LineWidth = 64
+
+	markerPrefix = "-----"
+	beginPrefix  = "-----BEGIN "
+	endPrefix    = "-----END "
+
+	headerVersion  = "Version"
+	headerLength   = "Length"
+	headerChecksum = "Checksum"
+)
+
+// Begin returns the begin marker of the blocks of the type.
+func Begin(typ string) string {
+	return beginPrefix + typ + markerPrefix
+}
+
+// End returns the end marker of the blocks of the type.
+func End(typ string) string {
+	return endPrefix + typ + markerPrefix
+}
+
+// BlockType returns the type of the block that begins at the line, or false if the line is not a begin marker.
+func BlockType(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, beginPrefix) || !strings.HasSuffix(line, markerPrefix) || len(line) <= len(beginPrefix)+len(markerPrefix) {
+		return "", false
+	}
+	return line[len(beginPrefix) : len(line)-len(markerPrefix)], true
+}
+
+// IsArmored returns true if the string starts with a begin marker.
+func IsArmored(s string) bool {
+	firstLine, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	_, ok := BlockType(firstLine)
+	return ok
+}
+
+// Encode returns the armored block of the payload of the type.
+func Encode(typ string, payload []byte) string {
+	var b strings.Builder
+	b.WriteString(Begin(typ) + "\n")
+	fmt.Fprintf(&b, "%s: %d\n", headerVersion, Version)
+	fmt.Fprintf(&b, "%s: %d\n", headerLength, len(payload))
+	fmt.Fprintf(&b, "%s: %08x\n", headerChecksum, crc32.ChecksumIEEE(payload))
+	body := base64.StdEncoding.EncodeToString(payload)
+	for len(body) > LineWidth {
+		b.WriteString(body[:LineWidth] + "\n")
+		body = body[LineWidth:]
+	}
+	b.WriteString(body + "\n")
+	b.WriteString(End(typ))
+	return b.String()
+}
+
+// Decode returns the payload of the armored block of the type.
+// The lines of the block may be indented, wrapped at any width, or end with CRLF.
+func Decode(typ string, s string) ([]byte, error) {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return nil, errors.New("armor: empty input")
+	}
+	got, ok := BlockType(lines[0])
+	if !ok {
+		return nil, fmt.Errorf("armor: missing begin marker %q, got %q", Begin(typ), truncate(lines[0]))
+	}
+	if got != typ {
+		return nil, fmt.Errorf("armor: got %s block, expected %s block", got, typ)
+	}
+	if lines[len(lines)-1] != End(typ) {
+		return nil, fmt.Errorf("armor: missing end marker %q, the block may be truncated", End(typ))
+	}
+	lines = lines[1 : len(lines)-1]
+
+	headers := map[string]string{}
+	for len(lines) > 0 {
+		// Base64 never contains colons, so the header lines are told apart from the body.
+		key, value, found := strings.Cut(lines[0], ":")
+		if !found {
+			break
+		}
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		lines = lines[1:]
+	}
+
+	if v := headers[headerVersion]; v != strconv.Itoa(Version) {
+		return nil, fmt.Errorf("armor: unsupported version %q, expected %d", v, Version)
+	}
+	length, err := strconv.Atoi(headers[headerLength])
+	if err != nil {
+		return nil, fmt.Errorf("armor: invalid %s header %q", headerLength, headers[headerLength])
+	}
+	checksum, err := strconv.ParseUint(headers[headerChecksum], 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("armor: invalid %s header %q", headerChecksum, headers[headerChecksum])
+	}
+
+	body := strings.Join(lines, "")
+	payload, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		var corrupt base64.CorruptInputError
+		if errors.As(err, &corrupt) {
+			line, col := position(lines, int(corrupt))
+			return nil, fmt.Errorf("armor: invalid base64 at line %d column %d of the body", line, col)
+		}
+		return nil, fmt.Errorf("armor: invalid base64 in the body: %v", err)
+	}
+	if len(payload) != length {
+		return nil, fmt.Errorf("armor: body has %d bytes, expected %d bytes; lines of the body may be missing", len(payload), length)
+	}
+	if sum := crc32.ChecksumIEEE(payload); sum != uint32(checksum) {
+		return nil, fmt.Errorf("armor: checksum of the body is %08x, expected %08x; the body is modified", sum, checksum)
+	}
+	return payload, nil
+}
+
+// position returns the 1-based line and column of the offset in the joined lines.
+func position(lines []string, offset int) (int, int) {
+	for i, line := range lines {
+		if offset < len(line) {
+			return i + 1, offset + 1
+		}
+		offset -= len(line)
+	}
+	// The offset is at the end of the body, such as a missing padding.
+	if len(lines) == 0 {
+		return 1, 1
+	}
+	return len(lines), len(lines[len(lines)-1]) + 1
+}
+
+// truncate returns the prefix of the line for error messages.
+func truncate(line string) string {
+	if len(line) > 32 {
+		return line[:32] + "..."
+	}
+	return line
+}
@@ -0,0 +1,133 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package armor
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// rewrap returns the block with the body wrapped at width, as terminals do.
+func rewrap(block string, width int) string {
+	lines := strings.Split(block, "\n")
+	header, body, footer := lines[:4], strings.Join(lines[4:len(lines)-1], ""), lines[len(lines)-1]
+	var wrapped []string
+	for len(body) > width {
+		wrapped = append(wrapped, body[:width])
+		body = body[width:]
+	}
+	wrapped = append(wrapped, body)
+	return strings.Join(append(append(header, wrapped...), footer), "\n")
+}
+
+func TestEncodeDecode(t *testing.T) {
+	t.Parallel()
+
+	const typ = "TEST DATA"
+	payload := bytes.Repeat([]byte("pam-sshca armored payload "), 10)
+	block := Encode(typ, payload)
+	lines := strings.Split(block, "\n")
+
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{
+			name:  "encoded",
+			input: block,
+		},
+		{
+			name:  "rewrapped, indented and CRLF",
+			input: "  " + strings.ReplaceAll(rewrap(block, 23), "\n", "\r\n  ") + "\r\n",
+		},
+		{
+			name:    "empty",
+			input:   " \n",
+			wantErr: "empty input",
+		},
+		{
+			name:    "missing begin marker",
+			input:   strings.Join(lines[1:], "\n"),
+			wantErr: "missing begin marker",
+		},
+		{
+			name:    "another type",
+			input:   strings.ReplaceAll(block, typ, "OTHER DATA"),
+			wantErr: "got OTHER DATA block, expected TEST DATA block",
+		},
+		{
+			name:    "truncated",
+			input:   strings.Join(lines[:len(lines)-2], "\n"),
+			wantErr: "missing end marker",
+		},
+		{
+			name:    "unsupported version",
+			input:   strings.Replace(block, "Version: 1", "Version: 2", 1),
+			wantErr: "unsupported version",
+		},
+		{
+			name:    "missing header",
+			input:   strings.Join(append(lines[:1:1], lines[2:]...), "\n"),
+			wantErr: "unsupported version",
+		},
+		{
+			name:    "missing line of the body",
+			input:   strings.Join(append(lines[:5:5], lines[6:]...), "\n"),
+			wantErr: "expected 260 bytes; lines of the body may be missing",
+		},
+		{
+			name:    "invalid character",
+			input:   strings.Replace(block, lines[5], lines[5][:10]+"*"+lines[5][11:], 1),
+			wantErr: "invalid base64 at line 2 column 11 of the body",
+		},
+		{
+			name:    "modified character",
+			input:   strings.Replace(block, lines[5], lines[5][:10]+flip(lines[5][10])+lines[5][11:], 1),
+			wantErr: "the body is modified",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode(typ, tt.input)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Decode() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Decode() unexpected error: %v", err)
+			}
+			if !bytes.Equal(got, payload) {
+				t.Errorf("Decode() = %q, want %q", got, payload)
+			}
+		})
+	}
+}
+
+// flip returns another base64 character than c.
+func flip(c byte) string {
+	if c == 'A' {
+		return "B"
+	}
+	return "A"
+}
+
+func TestIsArmored(t *testing.T) {
+	t.Parallel()
+	tests := map[string]bool{
+		Encode("TEST DATA", []byte("data")):              true,
+		"  -----BEGIN SSH SIGNATURE-----\nabc":           true,
+		"eyJEYXRhIjoi":                                   false,
+		"-----BEGIN -----":                               false,
+		"ssh-ed25519 AAAA -----BEGIN SSH SIGNATURE-----": false,
+	}
+	for input, want := range tests {
+		if got := IsArmored(input); got != want {
+			t.Errorf("IsArmored(%q) = %v, want %v", input, got, want)
+		}
+	}
+}
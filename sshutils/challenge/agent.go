@@ -0,0 +1,33 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package challenge
+
+import (
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// SignWithAgent signs the data by the key in the ssh-agent. RSA keys sign with SHA-512 instead of the deprecated SHA-1.
+// The private key of a certificate may be loaded with or without the certificate, so the key of the certificate
+// signs the data if the certificate fails to.
+func SignWithAgent(ag agent.Agent, key ssh.PublicKey, data []byte) (*ssh.Signature, error) {
+	sig, err := signWithAgent(ag, key, data)
+	if cert, ok := key.(*ssh.Certificate); ok && err != nil {
+		sig, err = signWithAgent(ag, cert.Key, data)
+	}
+	return sig, err
+}
+
+func signWithAgent(ag agent.Agent, key ssh.PublicKey, data []byte) (*ssh.Signature, error) {
+	if ext, ok := ag.(agent.ExtendedAgent); ok {
+		keyType := key.Type()
+		if cert, ok := key.(*ssh.Certificate); ok {
+			keyType = cert.Key.Type()
+		}
+		if keyType == ssh.KeyAlgoRSA {
+			return ext.SignWithFlags(key, data, agent.SignatureFlagRsaSha512)
+		}
+	}
+	return ag.Sign(key, data)
+}
@@ -0,0 +1,52 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package challenge
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestSignWithAgent(t *testing.T) {
+	t.Parallel()
+
+	cert, pub, priv := testSSHCertificate(t, "test_user")
+	data := []byte("data")
+	tests := []struct {
+		name string
+		// withCert is true if the private key is added to the ssh-agent with the certificate.
+		withCert bool
+		key      ssh.PublicKey
+	}{
+		{name: "certificate", withCert: true, key: cert},
+		{name: "certificate of a key loaded without it", key: cert},
+		{name: "public key", key: pub},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ag := agent.NewKeyring()
+			added := agent.AddedKey{PrivateKey: priv}
+			if tt.withCert {
+				added.Certificate = cert
+			}
+			if err := ag.Add(added); err != nil {
+				t.Fatal(err)
+			}
+			sig, err := SignWithAgent(ag, tt.key, data)
+			if err != nil {
+				t.Fatalf("SignWithAgent() error = %v", err)
+			}
+			if sig.Format != ssh.KeyAlgoRSASHA512 {
+				t.Errorf("SignWithAgent() signature format = %s, want %s", sig.Format, ssh.KeyAlgoRSASHA512)
+			}
+			if err := pub.Verify(data, sig); err != nil {
+				t.Errorf("SignWithAgent() signature fails verification: %v", err)
+			}
+		})
+	}
+}
@@ -7,9 +7,30 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/theparanoids/pam-ysshca/sshutils/armor"
 	"golang.org/x/crypto/ssh"
 )
 
+var (
+	// ErrMalformed is wrapped by the errors of VerifyResponse if the response cannot be parsed, such as a truncated paste,
+	// as opposed to a response that fails the verification.
+	ErrMalformed = errors.New("malformed challenge response")
+	// ErrExpired is wrapped by the errors of VerifyResponse if the challenge has expired.
+	ErrExpired = errors.New("challenge expired")
+)
+
+const (
+	// RequestType is the armor type of the challenge requests.
+	RequestType = "PAM-SSHCA CHALLENGE"
+	// ResponseType is the armor type of the challenge responses.
+	ResponseType = "PAM-SSHCA RESPONSE"
+)
+
 // Data defines format to be used for ssh challenge-response.
 type Data struct {
 	// Data is the random data for the private key to sign.
@@ -47,12 +68,41 @@ func (c *Data) Unmarshal(data []byte) error {
 	return json.Unmarshal(cBytes, c)
 }
 
+// MarshalArmored returns the armored block of the JSON for Data, with the armor type typ.
+func (c *Data) MarshalArmored(typ string) (string, error) {
+	cBytes, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return armor.Encode(typ, cBytes), nil
+}
+
+// UnmarshalArmored parses the armored block of the armor type typ and stores the result in the challenge data.
+func (c *Data) UnmarshalArmored(typ string, data string) error {
+	cBytes, err := armor.Decode(typ, data)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(cBytes, c); err != nil {
+		return fmt.Errorf("invalid challenge data in %s block: %v", typ, err)
+	}
+	return nil
+}
+
 // Challenge encapsulates the logic to generate challenge data and to verify a challenge response.
 type Challenge struct {
 	data *Data
 	key  ssh.PublicKey
+	// context is the context that the challenge is bound to. It is nil for the challenge of random data.
+	context *Context
+	// signature and sshsig are the verified response, see Signature and SSHSig.
+	signature *ssh.Signature
+	sshsig    string
 }
 
+// timeNow returns the current time. It is a variable for testing.
+var timeNow = time.Now
+
 // NewChallenge returns a new challenge.
 func NewChallenge(key ssh.PublicKey) (*Challenge, error) {
 	c, err := NewData()
@@ -74,11 +124,87 @@ func (c *Challenge) ChallengeRequest() ([]byte, error) {
 	return cBytes, nil
 }
 
+// ArmoredChallengeRequest returns the armored block of the challenge data, which survives line wrapping.
+func (c *Challenge) ArmoredChallengeRequest() (string, error) {
+	return c.data.MarshalArmored(RequestType)
+}
+
 // VerifyResponse returns nil if the public key of the challenge can verify the response data.
+// For the challenge bound to a context, the response must be for the same context,
+// and the challenge must not have expired.
+// The response is either the serialized or armored challenge data signed by cryptoauth-client,
+// or the armored SSHSIG signature of SSHSigMessage (see VerifySSHSig).
 func (c *Challenge) VerifyResponse(resp string) error {
+	if IsSSHSig(resp) {
+		// The SSHSIG message is derived from the issued context, so only the expiry is left to check.
+		if err := c.checkExpiry(); err != nil {
+			return err
+		}
+		if err := VerifySSHSig(c.key, c.data.Data, resp); err != nil {
+			return err
+		}
+		c.sshsig = strings.TrimSpace(resp)
+		return nil
+	}
 	respCh := &Data{}
-	if err := respCh.Unmarshal([]byte(resp)); err != nil {
+	if armor.IsArmored(resp) {
+		if err := respCh.UnmarshalArmored(ResponseType, resp); err != nil {
+			return fmt.Errorf("%w: %v", ErrMalformed, err)
+		}
+	} else if err := respCh.Unmarshal([]byte(resp)); err != nil {
+		return fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	if c.context != nil {
+		respCtx := &Context{}
+		if err := json.Unmarshal(respCh.Data, respCtx); err != nil {
+			return fmt.Errorf("%w: invalid challenge context in response: %v", ErrMalformed, err)
+		}
+		if err := c.context.verify(respCtx, timeNow()); err != nil {
+			return err
+		}
+	}
+	return c.VerifySignature(&respCh.Signature)
+}
+
+// VerifySignature returns nil if the public key of the challenge can verify the signature of Payload,
+// such as the signature by the ssh-agent, and the challenge has not expired.
+func (c *Challenge) VerifySignature(sig *ssh.Signature) error {
+	if err := c.checkExpiry(); err != nil {
 		return err
 	}
-	return c.key.Verify(c.data.Data, &respCh.Signature)
+	if err := c.key.Verify(c.data.Data, sig); err != nil {
+		return err
+	}
+	c.signature = sig
+	return nil
+}
+
+// checkExpiry returns an error wrapping ErrExpired if the challenge bound to a context has expired.
+func (c *Challenge) checkExpiry() error {
+	if c.context != nil && timeNow().Unix() >= c.context.ExpiresAt {
+		return fmt.Errorf("%w at %s", ErrExpired, time.Unix(c.context.ExpiresAt, 0).Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Key returns the public key that the challenge is issued for.
+func (c *Challenge) Key() ssh.PublicKey {
+	return c.key
+}
+
+// Payload returns the data of the challenge to sign, which is the JSON of the Context for the challenge bound to a context.
+func (c *Challenge) Payload() []byte {
+	return c.data.Data
+}
+
+// Signature returns the signature of Payload in the verified response.
+// It is nil before the response is verified, or if the response is an SSHSIG signature.
+func (c *Challenge) Signature() *ssh.Signature {
+	return c.signature
+}
+
+// SSHSig returns the armored SSHSIG signature in the verified response.
+// It is empty before the response is verified, or if the response is not an SSHSIG signature.
+func (c *Challenge) SSHSig() string {
+	return c.sshsig
 }
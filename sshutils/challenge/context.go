@@ -0,0 +1,125 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package challenge
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ContextVersion is the version of the Context payload.
+const ContextVersion = 1
+
+// Context is the payload of a challenge that binds the challenge response to the authentication request.
+// A response signed for one request cannot be relayed into another request, or used after the challenge expires.
+type Context struct {
+	// Version is the version of the payload.
+	Version int `json:"ver"`
+	// Host is the hostname of the host that issues the challenge.
+	Host string `json:"host"`
+	// User is the user to authenticate.
+	User string `json:"user"`
+	// Service is the PAM service name, such as "sudo".
+	Service string `json:"service"`
+	// CommandHash is the hex encoded SHA-256 hash of the command line of the PAM application.
+	CommandHash string `json:"cmdHash"`
+	// Target is the user that the command runs as, such as the user of `sudo -u`. It is empty if unknown.
+	Target string `json:"target,omitempty"`
+	// Command is the command line of the PAM application, so that the signed context records what the user approved.
+	Command string `json:"cmd,omitempty"`
+	// Key is the SHA256 fingerprint of the challenged key, so that the client picks it out of a bundle of certificates.
+	Key string `json:"key,omitempty"`
+	// IssuedAt is the unix time when the challenge is issued.
+	IssuedAt int64 `json:"iat"`
+	// ExpiresAt is the unix time when the challenge expires.
+	ExpiresAt int64 `json:"exp"`
+	// Nonce is the random data that makes every challenge unique.
+	// It starts with the nonce of the authentication, if any, which is shared by the challenges of the authentication.
+	Nonce []byte `json:"nonce"`
+}
+
+// HashCommand returns the value of CommandHash for the command line.
+func HashCommand(cmd string) string {
+	sum := sha256.Sum256([]byte(cmd))
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseContext parses the payload of a challenge bound to a context.
+// The payload must be a Context of ContextVersion without unknown fields, and must have a nonce.
+func ParseContext(payload []byte) (*Context, error) {
+	ctx := &Context{}
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(ctx); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("unexpected data after the challenge context")
+	}
+	if ctx.Version != ContextVersion {
+		return nil, fmt.Errorf("unsupported challenge context version %d", ctx.Version)
+	}
+	if len(ctx.Nonce) == 0 {
+		return nil, fmt.Errorf("challenge context has no nonce")
+	}
+	return ctx, nil
+}
+
+// verify returns nil if the response context is the same as the issued context, and has not expired at now.
+func (c *Context) verify(resp *Context, now time.Time) error {
+	switch {
+	case resp.Version != c.Version:
+		return fmt.Errorf("challenge version %d, expected %d", resp.Version, c.Version)
+	case resp.Host != c.Host:
+		return fmt.Errorf("challenge is bound to host %q, expected %q", resp.Host, c.Host)
+	case resp.User != c.User:
+		return fmt.Errorf("challenge is bound to user %q, expected %q", resp.User, c.User)
+	case resp.Service != c.Service:
+		return fmt.Errorf("challenge is bound to service %q, expected %q", resp.Service, c.Service)
+	case resp.CommandHash != c.CommandHash || resp.Command != c.Command:
+		return fmt.Errorf("challenge is bound to another command")
+	case resp.Target != c.Target:
+		return fmt.Errorf("challenge is bound to target user %q, expected %q", resp.Target, c.Target)
+	case resp.Key != c.Key:
+		return fmt.Errorf("challenge is bound to key %s, expected %s", resp.Key, c.Key)
+	case resp.IssuedAt != c.IssuedAt || resp.ExpiresAt != c.ExpiresAt || string(resp.Nonce) != string(c.Nonce):
+		return fmt.Errorf("challenge response is for another challenge")
+	case now.Unix() >= c.ExpiresAt:
+		return fmt.Errorf("%w at %s", ErrExpired, time.Unix(c.ExpiresAt, 0).Format(time.RFC3339))
+	}
+	return nil
+}
+
+// NewContextChallenge returns a new challenge bound to the context, which expires after ttl.
+// The version, the issued and expiry time and the key of the context are set by the function.
+// Random data is appended to the nonce of the context, which is the nonce of the authentication if set.
+func NewContextChallenge(key ssh.PublicKey, ctx Context, ttl time.Duration) (*Challenge, error) {
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	now := timeNow()
+	ctx.Version = ContextVersion
+	ctx.IssuedAt = now.Unix()
+	ctx.ExpiresAt = now.Add(ttl).Unix()
+	ctx.Nonce = append(append([]byte{}, ctx.Nonce...), nonce...)
+	ctx.Key = ssh.FingerprintSHA256(key)
+
+	payload, err := json.Marshal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Challenge{
+		data:    &Data{Data: payload},
+		key:     key,
+		context: &ctx,
+	}, nil
+}
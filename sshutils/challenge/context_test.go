@@ -0,0 +1,200 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package challenge
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestContextChallenge(t *testing.T) {
+	t.Parallel()
+
+	cert, _, priv := testSSHCertificate(t, "test_user")
+	ag := agent.NewKeyring()
+	if err := ag.Add(agent.AddedKey{PrivateKey: priv, Certificate: cert}); err != nil {
+		t.Fatal(err)
+	}
+	// respond signs the challenge request as the client does.
+	respond := func(t *testing.T, ch *Challenge) string {
+		req, err := ch.ChallengeRequest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cd := &Data{}
+		if err := cd.Unmarshal(req); err != nil {
+			t.Fatal(err)
+		}
+		sig, err := ag.Sign(cert, cd.Data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cd.Signature = *sig
+		resp, err := cd.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(resp)
+	}
+
+	ctx := Context{
+		Host:        "host.example.com",
+		User:        "test_user",
+		Service:     "sudo",
+		CommandHash: HashCommand("sudo ls"),
+		Target:      "root",
+		Command:     "sudo ls",
+	}
+	newChallenge := func(t *testing.T, ctx Context, ttl time.Duration) *Challenge {
+		ch, err := NewContextChallenge(cert, ctx, ttl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ch
+	}
+	ch := newChallenge(t, ctx, time.Minute)
+
+	relayed := ctx
+	relayed.Host = "other.example.com"
+	otherTarget := ctx
+	otherTarget.Target = "other_user"
+
+	tests := []struct {
+		name    string
+		resp    string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			resp: respond(t, ch),
+		},
+		{
+			name:    "relayed from another host",
+			resp:    respond(t, newChallenge(t, relayed, time.Minute)),
+			wantErr: "bound to host",
+		},
+		{
+			name:    "relayed for another target user",
+			resp:    respond(t, newChallenge(t, otherTarget, time.Minute)),
+			wantErr: "bound to target user",
+		},
+		{
+			name:    "response for another challenge",
+			resp:    respond(t, newChallenge(t, ctx, time.Minute)),
+			wantErr: "another challenge",
+		},
+		{
+			name:    "random data",
+			resp:    respond(t, func() *Challenge { ch, _ := NewChallenge(cert); return ch }()),
+			wantErr: "invalid challenge context",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ch.VerifyResponse(tt.resp)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("VerifyResponse() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("VerifyResponse() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// The verified response is kept for the receipt of the grant.
+	valid := newChallenge(t, ctx, time.Minute)
+	if valid.Signature() != nil {
+		t.Errorf("Signature() = %v before the response is verified, want nil", valid.Signature())
+	}
+	if err := valid.VerifyResponse(respond(t, valid)); err != nil {
+		t.Fatal(err)
+	}
+	if sig := valid.Signature(); sig == nil || cert.Verify(valid.Payload(), sig) != nil {
+		t.Errorf("Signature() = %v, want the verified signature of Payload()", sig)
+	}
+
+	expired := newChallenge(t, ctx, 0)
+	if err := expired.VerifyResponse(respond(t, expired)); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("VerifyResponse() error = %v, want expired challenge", err)
+	}
+}
+
+func TestNewContextChallenge(t *testing.T) {
+	t.Parallel()
+
+	cert, _, _ := testSSHCertificate(t, "test_user")
+	ch, err := NewContextChallenge(cert, Context{Host: "host", User: "test_user"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &Context{}
+	if err := json.Unmarshal(ch.data.Data, got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != ContextVersion || got.Host != "host" || got.User != "test_user" {
+		t.Errorf("NewContextChallenge() payload = %+v", got)
+	}
+	if got.ExpiresAt-got.IssuedAt != 60 {
+		t.Errorf("NewContextChallenge() expires %d seconds after issued, want 60", got.ExpiresAt-got.IssuedAt)
+	}
+	if len(got.Nonce) == 0 {
+		t.Errorf("NewContextChallenge() payload has no nonce")
+	}
+
+	// The nonce of the authentication is the prefix of the nonces of its challenges.
+	authNonce := []byte{1, 2, 3, 4}
+	first, err := NewContextChallenge(cert, Context{Nonce: authNonce}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := NewContextChallenge(cert, Context{Nonce: authNonce}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(first.context.Nonce, authNonce) || !bytes.HasPrefix(second.context.Nonce, authNonce) {
+		t.Errorf("NewContextChallenge() nonces %x, %x don't start with %x", first.context.Nonce, second.context.Nonce, authNonce)
+	}
+	if bytes.Equal(first.context.Nonce, second.context.Nonce) {
+		t.Errorf("NewContextChallenge() generated the same nonce twice")
+	}
+}
+
+func TestParseContext(t *testing.T) {
+	t.Parallel()
+
+	cert, _, _ := testSSHCertificate(t, "test_user")
+	ch, err := NewContextChallenge(cert, Context{Host: "host", User: "test_user"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		payload string
+		wantErr bool
+	}{
+		{name: "context", payload: string(ch.Payload())},
+		{name: "random data", payload: "\x01\x02\x03", wantErr: true},
+		{name: "unsupported version", payload: `{"ver":2,"host":"host","user":"u","nonce":"AQ=="}`, wantErr: true},
+		{name: "unknown field", payload: `{"ver":1,"host":"host","user":"u","nonce":"AQ==","other":"x"}`, wantErr: true},
+		{name: "no nonce", payload: `{"ver":1,"host":"host","user":"u"}`, wantErr: true},
+		{name: "trailing data", payload: string(ch.Payload()) + `{}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := ParseContext([]byte(tt.payload)); (err != nil) != tt.wantErr {
+				t.Errorf("ParseContext() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package challenge
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Please refer to PROTOCOL.sshsig in OpenSSH for the format of SSHSIG.
+const (
+	// Namespace is the SSHSIG namespace of the challenges, which prevents
+	// the signatures for other purposes from being used as challenge responses.
+	Namespace = "pam-sshca@ysshca"
+	// SSHSigBegin and SSHSigEnd are the armor of SSHSIG signatures.
+	SSHSigBegin = "-----BEGIN SSH SIGNATURE-----"
+	SSHSigEnd   = "-----END SSH SIGNATURE-----"
+
+	sshsigMagic   = "SSHSIG"
+	sshsigVersion = 1
+)
+
+// sshsig is the SSHSIG signature blob following the magic preamble.
+type sshsig struct {
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Signature     []byte
+}
+
+// sshsigSignedData is the data signed in SSHSIG following the magic preamble.
+type sshsigSignedData struct {
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Hash          []byte
+}
+
+// SSHSigMessage returns the message of the challenge to sign by OpenSSH, e.g.
+// `printf %s <message> | ssh-keygen -Y sign -n pam-sshca@ysshca -f <key>`.
+func (c *Challenge) SSHSigMessage() string {
+	return sshsigMessage(c.data.Data)
+}
+
+// sshsigMessage returns the SSHSIG message of the challenge payload.
+func sshsigMessage(payload []byte) string {
+	return base64.StdEncoding.EncodeToString(payload)
+}
+
+// IsSSHSig returns true if the response is an armored SSHSIG signature.
+func IsSSHSig(resp string) bool {
+	return strings.HasPrefix(strings.TrimSpace(resp), SSHSigBegin)
+}
+
+// VerifySSHSig returns nil if the armored SSHSIG signature signs the SSHSIG message of the challenge payload
+// in the challenge namespace by the key, or by the underlying key of the certificate.
+func VerifySSHSig(key ssh.PublicKey, payload []byte, armored string) error {
+	armored = strings.TrimSpace(armored)
+	if !strings.HasPrefix(armored, SSHSigBegin) || !strings.HasSuffix(armored, SSHSigEnd) {
+		return fmt.Errorf("%w: missing SSH signature armor", ErrMalformed)
+	}
+	body := strings.Join(strings.Fields(armored[len(SSHSigBegin):len(armored)-len(SSHSigEnd)]), "")
+	blob, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return fmt.Errorf("%w: invalid base64 in SSH signature: %v", ErrMalformed, err)
+	}
+	if !bytes.HasPrefix(blob, []byte(sshsigMagic)) {
+		return fmt.Errorf("%w: invalid SSH signature magic", ErrMalformed)
+	}
+	var sig sshsig
+	if err := ssh.Unmarshal(blob[len(sshsigMagic):], &sig); err != nil {
+		return fmt.Errorf("%w: invalid SSH signature: %v", ErrMalformed, err)
+	}
+	if sig.Version != sshsigVersion {
+		return fmt.Errorf("unsupported SSH signature version %d", sig.Version)
+	}
+	if sig.Namespace != Namespace {
+		return fmt.Errorf("SSH signature namespace %q, expected %q", sig.Namespace, Namespace)
+	}
+
+	// The signing key must be the key of the challenge, or the underlying key of the certificate.
+	signer, err := ssh.ParsePublicKey(sig.PublicKey)
+	if err != nil {
+		return fmt.Errorf("%w: invalid public key in SSH signature: %v", ErrMalformed, err)
+	}
+	if cert, ok := signer.(*ssh.Certificate); ok {
+		signer = cert.Key
+	}
+	if cert, ok := key.(*ssh.Certificate); ok {
+		key = cert.Key
+	}
+	if !bytes.Equal(signer.Marshal(), key.Marshal()) {
+		return fmt.Errorf("SSH signature is signed by another key")
+	}
+
+	var h hash.Hash
+	switch sig.HashAlgorithm {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return fmt.Errorf("unsupported SSH signature hash algorithm %q", sig.HashAlgorithm)
+	}
+	h.Write([]byte(sshsigMessage(payload)))
+	signedData := append([]byte(sshsigMagic), ssh.Marshal(sshsigSignedData{
+		Namespace:     sig.Namespace,
+		Reserved:      sig.Reserved,
+		HashAlgorithm: sig.HashAlgorithm,
+		Hash:          h.Sum(nil),
+	})...)
+
+	signature := &ssh.Signature{}
+	if err := ssh.Unmarshal(sig.Signature, signature); err != nil {
+		return fmt.Errorf("%w: invalid signature in SSH signature: %v", ErrMalformed, err)
+	}
+	// PROTOCOL.sshsig forbids the SHA-1 signatures of RSA keys, so only rsa-sha2-256 and rsa-sha2-512 are accepted.
+	if signature.Format == ssh.KeyAlgoRSA {
+		return fmt.Errorf("SSH signature algorithm %s is not allowed, expected %s or %s", ssh.KeyAlgoRSA, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSASHA512)
+	}
+	return key.Verify(signedData, signature)
+}
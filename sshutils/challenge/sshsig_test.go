@@ -0,0 +1,187 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package challenge
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// sshsign returns the armored SSHSIG signature of the message as `ssh-keygen -Y sign` does.
+func sshsign(t *testing.T, signer ssh.Signer, namespace, hashAlg, message string) string {
+	return sshsignWithAlgorithm(t, signer, ssh.KeyAlgoRSASHA512, namespace, hashAlg, message)
+}
+
+// sshsignWithAlgorithm is sshsign with the signature algorithm of RSA keys.
+func sshsignWithAlgorithm(t *testing.T, signer ssh.Signer, rsaAlg, namespace, hashAlg, message string) string {
+	var digest []byte
+	switch hashAlg {
+	case "sha256":
+		sum := sha256.Sum256([]byte(message))
+		digest = sum[:]
+	case "sha512":
+		sum := sha512.Sum512([]byte(message))
+		digest = sum[:]
+	}
+	signedData := append([]byte(sshsigMagic), ssh.Marshal(sshsigSignedData{
+		Namespace:     namespace,
+		HashAlgorithm: hashAlg,
+		Hash:          digest,
+	})...)
+	var sig *ssh.Signature
+	var err error
+	if algSigner, ok := signer.(ssh.AlgorithmSigner); ok && signer.PublicKey().Type() == ssh.KeyAlgoRSA {
+		sig, err = algSigner.SignWithAlgorithm(rand.Reader, signedData, rsaAlg)
+	} else {
+		sig, err = signer.Sign(rand.Reader, signedData)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob := append([]byte(sshsigMagic), ssh.Marshal(sshsig{
+		Version:       sshsigVersion,
+		PublicKey:     signer.PublicKey().Marshal(),
+		Namespace:     namespace,
+		HashAlgorithm: hashAlg,
+		Signature:     ssh.Marshal(sig),
+	})...)
+	body := base64.StdEncoding.EncodeToString(blob)
+	var lines []string
+	for len(body) > 70 {
+		lines = append(lines, body[:70])
+		body = body[70:]
+	}
+	lines = append(lines, body)
+	return SSHSigBegin + "\n" + strings.Join(lines, "\n") + "\n" + SSHSigEnd
+}
+
+func TestVerifyResponse_SSHSig(t *testing.T) {
+	t.Parallel()
+
+	cert, _, priv := testSSHCertificate(t, "test_user")
+	rsaSigner, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edSigner, err := ssh.NewSignerFromKey(edPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSigner, err := ssh.NewSignerFromKey(otherPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := Context{Host: "host.example.com", User: "test_user"}
+	newChallenge := func(t *testing.T, key ssh.PublicKey, ttl time.Duration) *Challenge {
+		ch, err := NewContextChallenge(key, ctx, ttl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ch
+	}
+	certCh := newChallenge(t, cert, time.Minute)
+	edCh := newChallenge(t, edSigner.PublicKey(), time.Minute)
+	expiredCh := newChallenge(t, edSigner.PublicKey(), 0)
+
+	tests := []struct {
+		name    string
+		ch      *Challenge
+		resp    string
+		wantErr string
+	}{
+		{
+			name: "certificate key with sha512",
+			ch:   certCh,
+			resp: sshsign(t, rsaSigner, Namespace, "sha512", certCh.SSHSigMessage()),
+		},
+		{
+			name: "certificate key with rsa-sha2-256",
+			ch:   certCh,
+			resp: sshsignWithAlgorithm(t, rsaSigner, ssh.KeyAlgoRSASHA256, Namespace, "sha512", certCh.SSHSigMessage()),
+		},
+		{
+			name:    "certificate key with ssh-rsa (SHA-1)",
+			ch:      certCh,
+			resp:    sshsignWithAlgorithm(t, rsaSigner, ssh.KeyAlgoRSA, Namespace, "sha512", certCh.SSHSigMessage()),
+			wantErr: "not allowed",
+		},
+		{
+			name: "static key with sha256",
+			ch:   edCh,
+			resp: sshsign(t, edSigner, Namespace, "sha256", edCh.SSHSigMessage()),
+		},
+		{
+			name: "surrounding whitespace",
+			ch:   edCh,
+			resp: "\n" + sshsign(t, edSigner, Namespace, "sha512", edCh.SSHSigMessage()) + "\n",
+		},
+		{
+			name:    "another namespace",
+			ch:      edCh,
+			resp:    sshsign(t, edSigner, "file", "sha512", edCh.SSHSigMessage()),
+			wantErr: "namespace",
+		},
+		{
+			name:    "another key",
+			ch:      edCh,
+			resp:    sshsign(t, otherSigner, Namespace, "sha512", edCh.SSHSigMessage()),
+			wantErr: "another key",
+		},
+		{
+			name:    "another challenge",
+			ch:      edCh,
+			resp:    sshsign(t, edSigner, Namespace, "sha512", newChallenge(t, edSigner.PublicKey(), time.Minute).SSHSigMessage()),
+			wantErr: "did not verify",
+		},
+		{
+			name:    "unsupported hash algorithm",
+			ch:      edCh,
+			resp:    sshsign(t, edSigner, Namespace, "md5", edCh.SSHSigMessage()),
+			wantErr: "hash algorithm",
+		},
+		{
+			name:    "expired",
+			ch:      expiredCh,
+			resp:    sshsign(t, edSigner, Namespace, "sha512", expiredCh.SSHSigMessage()),
+			wantErr: "expired",
+		},
+		{
+			name:    "missing end armor",
+			ch:      edCh,
+			resp:    strings.TrimSuffix(sshsign(t, edSigner, Namespace, "sha512", edCh.SSHSigMessage()), SSHSigEnd),
+			wantErr: "armor",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.ch.VerifyResponse(tt.resp)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("VerifyResponse() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("VerifyResponse() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+// Package nonce enforces the single use of nonce certificates with a local replay database.
+package nonce
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/theparanoids/ysshra/keyid"
+	"golang.org/x/crypto/ssh"
+)
+
+// DefaultPath is the path of the replay database used by PAM-SSHCA.
+const DefaultPath = "/var/lib/pam_sshca/nonce.db"
+
+// ErrReplayed is returned when a nonce certificate has already been used.
+var ErrReplayed = errors.New("nonce certificate has already been used")
+
+// DB is the replay database of nonce certificates.
+// Each line of the database records a used certificate in format
+// `<CA fingerprint> <serial> <valid before> <transID>`.
+// The database is protected by an flock(2) on a separate lock file,
+// so that it is safe for concurrent PAM invocations.
+// The database, its lock file and its directory must be owned by root, so that users cannot forge or remove the entries.
+type DB struct {
+	path string
+	// owner is the uid that must own the database, which is root except in the tests.
+	owner int
+	now   func() time.Time
+}
+
+// NewDB returns the replay database at path.
+func NewDB(path string) *DB {
+	return &DB{
+		path:  path,
+		owner: 0,
+		now:   time.Now,
+	}
+}
+
+// IsNonce returns true if the Key ID of the certificate marks it as a nonce certificate.
+func IsNonce(cert *ssh.Certificate) bool {
+	kid, err := keyid.Unmarshal(cert.KeyId)
+	return err == nil && kid.IsNonce
+}
+
+type entry struct {
+	key         string
+	validBefore uint64
+}
+
+// newEntry returns the entry of the certificate, keyed by the fingerprint of its CA,
+// its serial and the transaction ID in its Key ID.
+func newEntry(cert *ssh.Certificate) entry {
+	var transID string
+	if kid, err := keyid.Unmarshal(cert.KeyId); err == nil {
+		transID = kid.TransID
+	}
+	return entry{
+		key:         fmt.Sprintf("%s %d %s", ssh.FingerprintSHA256(cert.SignatureKey), cert.Serial, transID),
+		validBefore: cert.ValidBefore,
+	}
+}
+
+// Used returns true if the certificate has been recorded in the database.
+func (d *DB) Used(cert *ssh.Certificate) (bool, error) {
+	unlock, err := d.lock(syscall.LOCK_SH)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	entries, err := d.read()
+	if err != nil {
+		return false, err
+	}
+	e := newEntry(cert)
+	for _, used := range entries {
+		if used.key == e.key {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Use records the certificate in the database, and returns ErrReplayed if it has been recorded before.
+// The entries that are no longer valid are pruned.
+func (d *DB) Use(cert *ssh.Certificate) error {
+	unlock, err := d.lock(syscall.LOCK_EX)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	entries, err := d.read()
+	if err != nil {
+		return err
+	}
+	e := newEntry(cert)
+	now := uint64(d.now().Unix())
+	var kept []entry
+	for _, used := range entries {
+		if used.key == e.key {
+			return ErrReplayed
+		}
+		if used.validBefore >= now {
+			kept = append(kept, used)
+		}
+	}
+	return d.write(append(kept, e))
+}
+
+// lock creates the directory of the database if needed, and locks the database.
+// The directory and the lock file must be owned by root and not writable by group or others.
+func (d *DB) lock(how int) (func(), error) {
+	dir := filepath.Dir(d.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.checkOwner(dir, info); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(d.path+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if info, err = f.Stat(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := d.checkOwner(f.Name(), info); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), how); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN) //nolint:errcheck
+		f.Close()
+	}, nil
+}
+
+func (d *DB) read() ([]entry, error) {
+	f, err := os.Open(d.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if err := d.checkOwner(d.path, info); err != nil {
+		return nil, err
+	}
+
+	var entries []entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), " ", 4)
+		if len(fields) != 4 {
+			continue
+		}
+		validBefore, err := strconv.ParseUint(fields[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, entry{
+			key:         strings.Join([]string{fields[0], fields[1], fields[3]}, " "),
+			validBefore: validBefore,
+		})
+	}
+	return entries, scanner.Err()
+}
+
+// checkOwner returns an error if the file is not owned by the owner of the database, or is writable by group or others.
+func (d *DB) checkOwner(path string, info os.FileInfo) error {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && int(stat.Uid) != d.owner {
+		return fmt.Errorf("%s is not owned by uid %d", path, d.owner)
+	}
+	if info.Mode().Perm()&0022 != 0 {
+		return fmt.Errorf("%s is writable by group or others", path)
+	}
+	return nil
+}
+
+// write replaces the database atomically, so that a crash doesn't corrupt the recorded entries.
+func (d *DB) write(entries []entry) error {
+	tmp, err := os.CreateTemp(filepath.Dir(d.path), filepath.Base(d.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	for _, e := range entries {
+		fields := strings.SplitN(e.key, " ", 3)
+		fmt.Fprintf(w, "%s %s %d %s\n", fields[0], fields[1], e.validBefore, fields[2])
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), d.path)
+}
@@ -0,0 +1,228 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package nonce
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/theparanoids/ysshra/keyid"
+	"golang.org/x/crypto/ssh"
+)
+
+func testNonceCert(t *testing.T, ca ssh.Signer, serial uint64, transID string, validBefore time.Time) *ssh.Certificate {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid := &keyid.KeyID{TransID: transID, IsNonce: true}
+	kidStr, err := kid.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := &ssh.Certificate{
+		Key:         sshPub,
+		Serial:      serial,
+		CertType:    ssh.UserCert,
+		KeyId:       kidStr,
+		ValidBefore: uint64(validBefore.Unix()),
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func testCA(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// newTestDB returns the replay database at path owned by the current user, so that the tests may run as non-root.
+func newTestDB(path string) *DB {
+	db := NewDB(path)
+	db.owner = os.Geteuid()
+	return db
+}
+
+func TestDB_Use(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(filepath.Join(t.TempDir(), "pam_sshca", "nonce.db"))
+	ca := testCA(t)
+	validBefore := time.Now().Add(time.Hour)
+	cert := testNonceCert(t, ca, 1, "trans-1", validBefore)
+
+	if used, err := db.Used(cert); err != nil || used {
+		t.Fatalf("Used() = %v, %v, want false, nil", used, err)
+	}
+	if err := db.Use(cert); err != nil {
+		t.Fatalf("Use() unexpected error: %v", err)
+	}
+	if used, err := db.Used(cert); err != nil || !used {
+		t.Fatalf("Used() = %v, %v, want true, nil", used, err)
+	}
+	if err := db.Use(cert); err != ErrReplayed {
+		t.Errorf("Use() error = %v, want %v", err, ErrReplayed)
+	}
+
+	// Certificates with another serial, transaction ID or CA are different nonces.
+	for _, other := range []*ssh.Certificate{
+		testNonceCert(t, ca, 2, "trans-1", validBefore),
+		testNonceCert(t, ca, 1, "trans-2", validBefore),
+		testNonceCert(t, testCA(t), 1, "trans-1", validBefore),
+	} {
+		if err := db.Use(other); err != nil {
+			t.Errorf("Use() unexpected error: %v", err)
+		}
+	}
+}
+
+func TestDB_Use_prune(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "nonce.db")
+	db := newTestDB(path)
+	ca := testCA(t)
+	now := time.Now()
+	expired := testNonceCert(t, ca, 1, "expired", now.Add(time.Minute))
+	if err := db.Use(expired); err != nil {
+		t.Fatal(err)
+	}
+
+	db.now = func() time.Time { return now.Add(time.Hour) }
+	if err := db.Use(testNonceCert(t, ca, 2, "valid", now.Add(2*time.Hour))); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := db.read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].key != newEntry(testNonceCert(t, ca, 2, "valid", now)).key {
+		t.Errorf("expired entry is not pruned, got %v", entries)
+	}
+}
+
+func TestDB_Use_concurrent(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(filepath.Join(t.TempDir(), "nonce.db"))
+	cert := testNonceCert(t, testCA(t), 1, "trans-1", time.Now().Add(time.Hour))
+
+	var wg sync.WaitGroup
+	results := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results <- db.Use(cert)
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	succeeded := 0
+	for err := range results {
+		if err == nil {
+			succeeded++
+		} else if err != ErrReplayed {
+			t.Errorf("Use() unexpected error: %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("nonce certificate is used %d times, want 1", succeeded)
+	}
+}
+
+func TestDB_insecureDir(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	db := newTestDB(filepath.Join(dir, "nonce.db"))
+	cert := testNonceCert(t, testCA(t), 1, "trans-1", time.Now().Add(time.Hour))
+	if err := db.Use(cert); err == nil {
+		t.Errorf("Use() expected error for insecure directory")
+	}
+}
+
+func TestDB_notOwnedByRoot(t *testing.T) {
+	t.Parallel()
+	if os.Geteuid() != 0 {
+		t.Skip("chown requires root")
+	}
+	cert := testNonceCert(t, testCA(t), 1, "trans-1", time.Now().Add(time.Hour))
+	tests := []struct {
+		name  string
+		chown func(dir string) error
+	}{
+		{
+			name:  "directory",
+			chown: func(dir string) error { return os.Chown(dir, 65534, 65534) },
+		},
+		{
+			name: "database",
+			chown: func(dir string) error {
+				if err := os.WriteFile(filepath.Join(dir, "nonce.db"), nil, 0600); err != nil {
+					return err
+				}
+				return os.Chown(filepath.Join(dir, "nonce.db"), 65534, 65534)
+			},
+		},
+		{
+			name: "lock file",
+			chown: func(dir string) error {
+				if err := os.WriteFile(filepath.Join(dir, "nonce.db.lock"), nil, 0600); err != nil {
+					return err
+				}
+				return os.Chown(filepath.Join(dir, "nonce.db.lock"), 65534, 65534)
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			if err := tt.chown(dir); err != nil {
+				t.Fatal(err)
+			}
+			db := NewDB(filepath.Join(dir, "nonce.db"))
+			if _, err := db.Used(cert); err == nil {
+				t.Errorf("Used() expected error for the %s not owned by root", tt.name)
+			}
+			if err := db.Use(cert); err == nil {
+				t.Errorf("Use() expected error for the %s not owned by root", tt.name)
+			}
+		})
+	}
+}
+
+func TestIsNonce(t *testing.T) {
+	t.Parallel()
+	cert := testNonceCert(t, testCA(t), 1, "trans-1", time.Now().Add(time.Hour))
+	if !IsNonce(cert) {
+		t.Errorf("IsNonce() = false, want true")
+	}
+	cert.KeyId = "not a key ID"
+	if IsNonce(cert) {
+		t.Errorf("IsNonce() = true, want false")
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+// Package receipt records the signed challenges of the grants as receipts, which prove later
+// that the holder of the key approved the grant, such as the command of sudo.
+package receipt
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/theparanoids/pam-ysshca/sshutils/challenge"
+	"golang.org/x/crypto/ssh"
+)
+
+// Version is the version of the receipt format.
+const Version = 1
+
+// Receipt is the challenge signed during the authentication of a grant.
+// The statement of the grant is the challenge payload, that is the JSON of the challenge.Context,
+// which is signed either by the ssh-agent (Signature) or by `ssh-keygen -Y sign` (SSHSig).
+type Receipt struct {
+	// Version is the version of the receipt format.
+	Version int `json:"ver"`
+	// Key is the public key or certificate that signs the statement, in authorized_keys format.
+	Key string `json:"key"`
+	// Statement is the signed challenge payload.
+	Statement []byte `json:"statement"`
+	// Signature is the SSH signature of Statement.
+	Signature *ssh.Signature `json:"signature,omitempty"`
+	// SSHSig is the armored SSHSIG signature of the SSHSIG message of Statement.
+	SSHSig string `json:"sshsig,omitempty"`
+}
+
+// New returns the receipt of the challenge, whose response must have been verified.
+func New(ch *challenge.Challenge) (*Receipt, error) {
+	if ch.Signature() == nil && ch.SSHSig() == "" {
+		return nil, errors.New("challenge response has not been verified")
+	}
+	return &Receipt{
+		Version:   Version,
+		Key:       string(bytes.TrimSpace(ssh.MarshalAuthorizedKey(ch.Key()))),
+		Statement: ch.Payload(),
+		Signature: ch.Signature(),
+		SSHSig:    ch.SSHSig(),
+	}, nil
+}
+
+// Verify returns the signed statement and the key that signs it if the signature of the receipt is valid.
+// If the receipt is signed by a certificate, the certificate must be a user certificate signed by one of caKeys,
+// and valid at the time of the statement. The certificate is not checked if caKeys is empty.
+func (r *Receipt) Verify(caKeys []ssh.PublicKey) (*challenge.Context, ssh.PublicKey, error) {
+	if r.Version != Version {
+		return nil, nil, fmt.Errorf("unsupported receipt version %d", r.Version)
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(r.Key))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid key in receipt: %v", err)
+	}
+	switch {
+	case r.Signature != nil:
+		err = key.Verify(r.Statement, r.Signature)
+	case r.SSHSig != "":
+		err = challenge.VerifySSHSig(key, r.Statement, r.SSHSig)
+	default:
+		err = errors.New("no signature in receipt")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid signature: %v", err)
+	}
+	ctx, err := challenge.ParseContext(r.Statement)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid statement: %v", err)
+	}
+	if challenge.HashCommand(ctx.Command) != ctx.CommandHash {
+		return nil, nil, fmt.Errorf("invalid statement: command does not match its hash")
+	}
+
+	if cert, ok := key.(*ssh.Certificate); ok && len(caKeys) > 0 {
+		if err := checkCert(cert, caKeys, time.Unix(ctx.IssuedAt, 0)); err != nil {
+			return nil, nil, err
+		}
+	}
+	return ctx, key, nil
+}
+
+// checkCert returns nil if the certificate is a user certificate signed by one of caKeys, and valid at the time.
+func checkCert(cert *ssh.Certificate, caKeys []ssh.PublicKey, at time.Time) error {
+	if cert.CertType != ssh.UserCert {
+		return fmt.Errorf("certificate is not a user certificate")
+	}
+	trusted := false
+	for _, ca := range caKeys {
+		if bytes.Equal(cert.SignatureKey.Marshal(), ca.Marshal()) {
+			trusted = true
+			break
+		}
+	}
+	if !trusted {
+		return fmt.Errorf("invalid certificate: signed by untrusted CA %s", ssh.FingerprintSHA256(cert.SignatureKey))
+	}
+	// CheckCert verifies the signature and the validity period, but not the CA.
+	checker := &ssh.CertChecker{
+		Clock: func() time.Time { return at },
+	}
+	// The principals are not checked, because the statement records the user after the principal mapping.
+	var principal string
+	if len(cert.ValidPrincipals) > 0 {
+		principal = cert.ValidPrincipals[0]
+	}
+	if err := checker.CheckCert(principal, cert); err != nil {
+		return fmt.Errorf("invalid certificate: %v", err)
+	}
+	return nil
+}
+
+// Save writes the receipt in dir as a new file, and returns the path of the file.
+// An existing receipt is never overwritten, so dir may be an append-only directory (see chattr(1)).
+// dir must be owned by the current user and not writable by group or others.
+func Save(dir string, r *Receipt) (string, error) {
+	ctx, err := challenge.ParseContext(r.Statement)
+	if err != nil {
+		return "", fmt.Errorf("invalid statement: %v", err)
+	}
+	if err := checkDir(dir); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := ctx.Nonce
+	if len(nonce) > 8 {
+		nonce = nonce[:8]
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%d-%s.json", ctx.IssuedAt, hex.EncodeToString(nonce)))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0400)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return "", err
+	}
+	return path, f.Close()
+}
+
+// Load reads the receipt in the file.
+func Load(path string) (*Receipt, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &Receipt{}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("invalid receipt: %v", err)
+	}
+	return r, nil
+}
+
+// checkDir creates dir if needed, and checks that it is owned by the current user and not writable by group or others.
+func checkDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && int(stat.Uid) != os.Geteuid() {
+		return fmt.Errorf("%s is not owned by uid %d", dir, os.Geteuid())
+	}
+	if info.Mode().Perm()&0022 != 0 {
+		return fmt.Errorf("%s is writable by group or others", dir)
+	}
+	return nil
+}
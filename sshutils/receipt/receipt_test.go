@@ -0,0 +1,181 @@
+// Copyright 2026 Yahoo Inc.
+// Licensed under the terms of the Apache License 2.0. Please see LICENSE file in project root for terms.
+
+package receipt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/theparanoids/pam-ysshca/sshutils/challenge"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func newTestSigner(t *testing.T) (ssh.Signer, ed25519.PrivateKey) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer, priv
+}
+
+// newTestReceipt returns the receipt of a challenge signed by the certificate in the ssh-agent.
+func newTestReceipt(t *testing.T, ca ssh.Signer, validBefore uint64) *Receipt {
+	signer, priv := newTestSigner(t)
+	cert := &ssh.Certificate{
+		Key:             signer.PublicKey(),
+		KeyId:           "alice-key",
+		CertType:        ssh.UserCert,
+		ValidPrincipals: []string{"alice"},
+		ValidBefore:     validBefore,
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatal(err)
+	}
+	ag := agent.NewKeyring()
+	if err := ag.Add(agent.AddedKey{PrivateKey: priv, Certificate: cert}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := challenge.Context{
+		Host:        "host.example.com",
+		User:        "alice",
+		Service:     "sudo",
+		CommandHash: challenge.HashCommand("sudo -u bob ls"),
+		Target:      "bob",
+		Command:     "sudo -u bob ls",
+	}
+	ch, err := challenge.NewContextChallenge(cert, ctx, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(ch); err == nil {
+		t.Errorf("New() returned the receipt of an unverified challenge")
+	}
+	sig, err := ag.Sign(cert, ch.Payload())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ch.VerifySignature(sig); err != nil {
+		t.Fatal(err)
+	}
+	r, err := New(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestReceipt_Verify(t *testing.T) {
+	t.Parallel()
+	ca, _ := newTestSigner(t)
+	otherCA, _ := newTestSigner(t)
+	valid := newTestReceipt(t, ca, ssh.CertTimeInfinity)
+
+	tampered := *valid
+	tampered.Statement = []byte(strings.Replace(string(valid.Statement), `"bob"`, `"root"`, 1))
+
+	tests := []struct {
+		name    string
+		receipt *Receipt
+		caKeys  []ssh.PublicKey
+		wantErr string
+	}{
+		{
+			name:    "valid",
+			receipt: valid,
+			caKeys:  []ssh.PublicKey{otherCA.PublicKey(), ca.PublicKey()},
+		},
+		{
+			name:    "valid without checking CA",
+			receipt: valid,
+		},
+		{
+			name:    "untrusted CA",
+			receipt: valid,
+			caKeys:  []ssh.PublicKey{otherCA.PublicKey()},
+			wantErr: "invalid certificate",
+		},
+		{
+			name:    "certificate expired before the statement",
+			receipt: newTestReceipt(t, ca, uint64(time.Now().Add(-time.Hour).Unix())),
+			caKeys:  []ssh.PublicKey{ca.PublicKey()},
+			wantErr: "invalid certificate",
+		},
+		{
+			name:    "tampered statement",
+			receipt: &tampered,
+			caKeys:  []ssh.PublicKey{ca.PublicKey()},
+			wantErr: "invalid signature",
+		},
+		{
+			name:    "no signature",
+			receipt: &Receipt{Version: Version, Key: valid.Key, Statement: valid.Statement},
+			wantErr: "no signature",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctx, _, err := tt.receipt.Verify(tt.caKeys)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Verify() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if ctx.User != "alice" || ctx.Target != "bob" || ctx.Command != "sudo -u bob ls" {
+				t.Errorf("Verify() statement = %+v", ctx)
+			}
+		})
+	}
+}
+
+func TestSave(t *testing.T) {
+	t.Parallel()
+	ca, _ := newTestSigner(t)
+	r := newTestReceipt(t, ca, ssh.CertTimeInfinity)
+
+	dir := filepath.Join(t.TempDir(), "receipts")
+	path, err := Save(dir, r)
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	got, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, r) {
+		t.Errorf("Load() = %+v, want %+v", got, r)
+	}
+	if _, _, err := got.Verify([]ssh.PublicKey{ca.PublicKey()}); err != nil {
+		t.Errorf("Verify() of the saved receipt error = %v", err)
+	}
+
+	// An existing receipt is never overwritten.
+	if _, err := Save(dir, r); err == nil {
+		t.Errorf("Save() overwrote the existing receipt %s", path)
+	}
+
+	if err := os.Chmod(dir, 0770); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Save(dir, newTestReceipt(t, ca, ssh.CertTimeInfinity)); err == nil || !strings.Contains(err.Error(), "writable by group") {
+		t.Errorf("Save() error = %v, want error on the group writable directory", err)
+	}
+}